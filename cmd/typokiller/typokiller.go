@@ -2,91 +2,282 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	docopt "github.com/docopt/docopt-go"
+	"github.com/rhcarvalho/typokiller/pkg/apply"
 	"github.com/rhcarvalho/typokiller/pkg/fix"
 	"github.com/rhcarvalho/typokiller/pkg/read"
+	"github.com/rhcarvalho/typokiller/pkg/report"
+	"github.com/rhcarvalho/typokiller/pkg/spell"
+	"github.com/rhcarvalho/typokiller/pkg/stream"
 	"github.com/rhcarvalho/typokiller/pkg/types"
 )
 
+// version is the name and version of the tool, written in the header of
+// metadata streams.
+const version = "typokiller 0.3"
+
 func main() {
 	usage := `Usage:
-  typokiller read [options] PATH ...
-  typokiller fix
+  typokiller check [options] [--exclude=GLOB]... PATH ...
+  typokiller read [options] [--exclude=GLOB]... PATH ...
+  typokiller spell [options]
+  typokiller fix [--dry-run] [--output=FILE] [--session=FILE]
+  typokiller fix [--dry-run] [--output=FILE] --resume=SESSION
+  typokiller apply [--dry-run] FILE
+  typokiller validate [FILE ...]
+  typokiller validate --schema
 
 Interactive tool to find and fix typos in codebases.
 
 Options:
-  -h --help     Show this usage help
-  --format=EXT  Document format [default: go]
-  --version     Show version
+  -h --help          Show this usage help
+  --format=NAME      Document format of all files; by default, detected per file
+  --dict=FILE        Word list or Hunspell .dic file [default: /usr/share/dict/words]
+  -r --recursive     Read directories recursively, skipping .git, vendor, Godeps, testdata
+                     and paths in .gitignore and .typokillerignore files
+  --exclude=GLOB     Skip files and directories matching GLOB when reading recursively
+  --follow-symlinks  Follow symbolic links to directories when reading recursively
+  --strings          Also read string literals of Go code, like error messages
+  --identifiers      Also spellcheck the words of names declared in Go code
+  --tags=TAGS        Comma-separated build tags selecting the Go files to read
+  --schema           Print the JSON Schema of the metadata streams
+  --dry-run          Write the changes to STDOUT as a unified diff, instead of changing files
+  -o --output=FILE   Write the misspellings and the actions taken on them to FILE when quitting
+  --session=FILE     Save the state of fix to FILE after each action, to resume it later
+  --resume=SESSION   Restore the state of fix saved in SESSION instead of reading STDIN, and keep
+                     saving to it; misspellings in files changed since are marked as stale
+  --version          Show version
 
 Commands:
-  read       For each PATH, read the documentation of Go packages and outputs metadata to STDOUT
+  check      For each PATH, read and spellcheck the documentation and report potential misspells to STDOUT
+  read       For each PATH, read the documentation and outputs metadata to STDOUT
+  spell      Reads documentation metadata from STDIN and outputs spelling error information to STDOUT
   fix        Reads spelling error information from STDIN and allows for interative patching
+  apply      Applies the actions in FILE, written by fix --output, without interaction
+  validate   Checks that the metadata in each FILE, or in STDIN, conforms to the JSON Schema
 
 Available formats:
-  go         Go source code
-  adoc       AsciiDoc documents
+  go         Go source code (.go); PATH may be a package pattern like ./...
+  adoc       AsciiDoc documents (.adoc)
+  md         Markdown documents (.md, .markdown, .mdown, .mkd)
+  txt        Plain text files, like README and LICENSE (.txt)
+  py         Python source code (.py)
+  diff       Lines added by unified diffs; PATH is a patch file or - for STDIN (.diff, .patch)
+  wiki       MediaWiki XML dumps, optionally bzip2-compressed; PATH is a dump or - for STDIN
+
+Formats are detected from file extensions and names, so that directories with
+files in several formats are read in one run. Diffs are detected only when given
+as PATH, and MediaWiki dumps only with --format=wiki.
+
+Other formats are read by external readers: --format=NAME runs the executable
+typokiller-read-NAME found in PATH for each PATH, which must print the same
+metadata as typokiller read to STDOUT.
+
+Metadata is a stream of JSON records, one per line: a header with the version
+of the format, and then one record per package, as described by the schema
+printed by typokiller validate --schema.
+
+Exit status is 1 when check finds potential misspells, apply cannot apply some
+actions or validate finds invalid metadata, and 2 on any other error.
 `
-	arguments, _ := docopt.Parse(usage, nil, true, "typokiller 0.3", false)
+	arguments, _ := docopt.Parse(usage, nil, true, version, false)
 
 	var err error
-	if arguments["fix"].(bool) {
-		err = Fix()
-	} else {
-		format := arguments["--format"].(string)
-		err = Read(format, arguments["PATH"].([]string)...)
+	switch {
+	case arguments["check"].(bool):
+		var dirReader read.DirReader
+		if dirReader, err = newDirReader(arguments); err == nil {
+			err = Check(dirReader, arguments["--dict"].(string), arguments["PATH"].([]string)...)
+		}
+		if err == errMisspellings {
+			os.Exit(1)
+		}
+	case arguments["spell"].(bool):
+		err = Spell(arguments["--dict"].(string))
+	case arguments["fix"].(bool):
+		output, _ := arguments["--output"].(string)
+		if resume, ok := arguments["--resume"].(string); ok {
+			err = Resume(resume, arguments["--dry-run"].(bool), output)
+			break
+		}
+		session, _ := arguments["--session"].(string)
+		err = Fix(arguments["--dry-run"].(bool), output, session)
+	case arguments["apply"].(bool):
+		err = Apply(arguments["FILE"].([]string)[0], arguments["--dry-run"].(bool))
+		if err == errNotApplied {
+			os.Exit(1)
+		}
+	case arguments["validate"].(bool):
+		if arguments["--schema"].(bool) {
+			_, err = io.WriteString(os.Stdout, stream.Schema)
+			break
+		}
+		err = Validate(arguments["FILE"].([]string)...)
+		if err == errInvalid {
+			os.Exit(1)
+		}
+	default:
+		var dirReader read.DirReader
+		if dirReader, err = newDirReader(arguments); err == nil {
+			err = Read(dirReader, arguments["PATH"].([]string)...)
+		}
 	}
 	if err != nil {
 		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EPIPE {
 			// ignore broken pipe
 		} else {
-			log.Fatalln("error:", err)
+			log.Println("error:", err)
+			os.Exit(2)
+		}
+	}
+}
+
+// newDirReader returns the DirReader for the format and walking options in the
+// command line arguments. Without a format, the format of each file is
+// detected from its name.
+func newDirReader(arguments map[string]interface{}) (read.DirReader, error) {
+	goFormat := read.GoFormat{
+		Strings:     arguments["--strings"].(bool),
+		Identifiers: arguments["--identifiers"].(bool),
+	}
+	if tags, ok := arguments["--tags"].(string); ok && tags != "" {
+		goFormat.Tags = strings.Split(tags, ",")
+	}
+	var dirReader read.DirReader = read.AutoFormat{Formats: map[string]read.DirReader{"go": goFormat}}
+	if format, ok := arguments["--format"].(string); ok && format == "go" {
+		dirReader = goFormat
+	} else if ok {
+		var err error
+		if dirReader, err = read.LookupFormat(format); err != nil {
+			return nil, err
 		}
 	}
+	if !arguments["--recursive"].(bool) {
+		return dirReader, nil
+	}
+	r := read.NewRecursive(dirReader, arguments["--exclude"].([]string)...)
+	r.FollowSymlinks = arguments["--follow-symlinks"].(bool)
+	return r, nil
 }
 
-// Read reads the documentation in paths and outputs metadata to STDOUT.
-func Read(format string, paths ...string) error {
-	enc := json.NewEncoder(os.Stdout)
+// Read reads the documentation in paths using dirReader and outputs metadata
+// to STDOUT.
+func Read(dirReader read.DirReader, paths ...string) error {
+	enc := stream.NewEncoder(os.Stdout, version)
 	for _, path := range paths {
-		path, err := filepath.Abs(path)
+		if path != "-" {
+			var err error
+			path, err = filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+		}
+		err := readPath(dirReader, path, func(pkg *types.Package) error {
+			return enc.Encode(pkg)
+		})
 		if err != nil {
 			return err
 		}
-		var dirReader read.DirReader
-		switch format {
-		case "adoc":
-			dirReader = read.AsciiDocFormat{}
-		default:
-			dirReader = read.GoFormat{}
-		}
-		pkgs, err := dirReader.ReadDir(path)
-		if err != nil {
+	}
+	return nil
+}
+
+// readPath reads the documentation in path using dirReader, calling fn for
+// each package. Readers that implement read.StreamReader pass packages to fn as
+// soon as they are read.
+func readPath(dirReader read.DirReader, path string, fn func(*types.Package) error) error {
+	if sr, ok := dirReader.(read.StreamReader); ok {
+		return sr.ReadStream(path, fn)
+	}
+	pkgs, err := dirReader.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if err := fn(pkg); err != nil {
 			return err
 		}
-		for _, pkg := range pkgs {
-			err = enc.Encode(pkg)
-			if err != nil {
-				return err
+	}
+	return nil
+}
+
+// errMisspellings is returned by Check when potential misspells are found.
+var errMisspellings = errors.New("found potential misspells")
+
+// Check reads the documentation in paths using dirReader, spellchecks it using
+// the dictionary in dictPath and reports potential misspells to STDOUT. It
+// returns errMisspellings if any potential misspell is found.
+func Check(dirReader read.DirReader, dictPath string, paths ...string) error {
+	dict, err := spell.LoadDictionary(dictPath)
+	if err != nil {
+		return err
+	}
+	reporter := report.NewPlain(os.Stdout)
+	for _, path := range paths {
+		err := readPath(dirReader, path, func(pkg *types.Package) error {
+			for _, text := range spell.NewChecker(dict).Spellcheck(pkg) {
+				if err := reporter.Report(text); err != nil {
+					return err
+				}
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
+	if reporter.Count() > 0 {
+		return errMisspellings
+	}
 	return nil
 }
 
+// Spell reads documentation metadata from STDIN, spellchecks it using the
+// dictionary in dictPath and outputs the documentation with potential misspells
+// to STDOUT.
+func Spell(dictPath string) error {
+	dict, err := spell.LoadDictionary(dictPath)
+	if err != nil {
+		return err
+	}
+	dec := stream.NewDecoder(os.Stdin)
+	enc := stream.NewEncoder(os.Stdout, version)
+	for {
+		pkg, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading STDIN: %v", err)
+		}
+		misspelled := spell.NewChecker(dict).Spellcheck(pkg)
+		if len(misspelled) == 0 {
+			continue
+		}
+		err = enc.Encode(&types.Package{Name: pkg.Name, Documentation: misspelled})
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // Fix reads documentation metadata from STDIN and presents an interactive user
-// interface to perform actions on potential misspells.
-func Fix() error {
+// interface to perform actions on potential misspells. With dryRun, changes are
+// written to STDOUT as a unified diff once the interface is closed, so that
+// they are not mixed with it on the terminal. With output, the misspellings and
+// the actions taken on them are written to the file output when the interface
+// is closed, to be applied later. With session, the state of the interface is
+// saved to the file session after each action, and the file is removed when
+// quitting after applying all changes.
+func Fix(dryRun bool, output, session string) error {
 	misspellings := make(chan *types.Misspelling)
 	errs := make(chan error)
 
@@ -95,35 +286,195 @@ func Fix() error {
 		defer close(misspellings)
 		defer close(errs)
 
-		reader := bufio.NewReaderSize(os.Stdin, 64*1024*1024) // 64 MB
-		var err error
+		err := decodeMisspellings(os.Stdin, func(m *types.Misspelling) {
+			misspellings <- m
+		})
+		if err != nil {
+			errs <- fmt.Errorf("reading STDIN: %v", err)
+		}
+	}()
 
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				break
-			}
+	ui := newUI(dryRun)
+	ui.SessionPath = session
+	if err := ui.Fix(misspellings, errs); err != nil {
+		return err
+	}
+	return writeOutput(output, ui)
+}
+
+// Resume restores the state of fix saved in the file session, and presents the
+// interactive user interface, saving its state to session as in Fix. dryRun and
+// output are as in Fix.
+func Resume(session string, dryRun bool, output string) error {
+	ui := newUI(dryRun)
+	if err := ui.Resume(session); err != nil {
+		return err
+	}
+	ui.SessionPath = session
+	if err := ui.Mainloop(nil); err != nil {
+		return err
+	}
+	return writeOutput(output, ui)
+}
+
+// newUI returns the user interface of fix, writing changes to STDOUT as a
+// unified diff with dryRun.
+func newUI(dryRun bool) *fix.UI {
+	ui := fix.NewUI()
+	if dryRun {
+		ui.DryRun = os.Stdout
+	}
+	return ui
+}
+
+// writeOutput writes the misspellings of ui, with the actions taken on them,
+// to the file output, if set.
+func writeOutput(output string, ui *fix.UI) error {
+	if output == "" {
+		return nil
+	}
+	return writeDecisions(output, ui.Misspellings)
+}
 
-			var pkg *types.Package
-			if err = json.Unmarshal(line, &pkg); err != nil {
-				errs <- fmt.Errorf("parsing '%s': %v", line, err)
-				continue
+// decodeMisspellings reads documentation metadata from r, calling fn for each
+// misspelling, linked to its text and package.
+func decodeMisspellings(r io.Reader, fn func(*types.Misspelling)) error {
+	dec := stream.NewDecoder(r)
+	for {
+		pkg, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		for _, text := range pkg.Documentation {
+			text.Package = pkg
+			for _, misspelling := range text.Misspellings {
+				misspelling.Text = text
+				fn(misspelling)
 			}
+		}
+	}
+}
 
-			for _, text := range pkg.Documentation {
-				text.Package = pkg
-				for _, misspelling := range text.Misspellings {
-					misspelling.Text = text
-					misspellings <- misspelling
-				}
+// writeDecisions writes the packages of misspellings, with the actions taken
+// on them, to the file path. Filenames under the current directory are made
+// relative to it, so that the actions can be applied in another checkout.
+func writeDecisions(path string, misspellings []*types.Misspelling) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := stream.NewEncoder(f, version)
+	for _, pkg := range fix.Packages(misspellings) {
+		for _, text := range pkg.Documentation {
+			rel, err := filepath.Rel(cwd, text.Position.Filename)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				text.Position.Filename = rel
 			}
 		}
+		if err := enc.Encode(pkg); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// errNotApplied is returned by Apply when some actions cannot be applied.
+var errNotApplied = errors.New("some actions were not applied")
+
+// Apply reads misspellings and the actions taken on them from the file path, or
+// from STDIN if path is -, as written by fix --output, and applies them. It
+// reports the edits and errors by file to STDOUT or, with dryRun, writes the
+// changes to STDOUT as a unified diff and reports to STDERR. It returns
+// errNotApplied if any action cannot be applied.
+func Apply(path string, dryRun bool) error {
+	var r io.Reader = os.Stdin
+	name := "STDIN"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, name = f, path
+	}
+	var misspellings []*types.Misspelling
+	err := decodeMisspellings(r, func(m *types.Misspelling) {
+		misspellings = append(misspellings, m)
+	})
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", name, err)
+	}
 
-		if err != nil && err != io.EOF {
-			errs <- err
-			return
+	var results []*apply.Result
+	report := os.Stdout
+	if dryRun {
+		report = os.Stderr
+		if results, err = apply.Diff(os.Stdout, misspellings); err != nil {
+			return err
 		}
-	}()
+	} else {
+		results = apply.Apply(misspellings)
+	}
+	failed := false
+	for _, r := range results {
+		if r.Err != nil {
+			failed = true
+			fmt.Fprintf(report, "%s: %v\n", r.Filename, r.Err)
+		} else {
+			fmt.Fprintf(report, "%s: %d edits\n", r.Filename, r.Edits)
+		}
+		for _, f := range r.Failed {
+			failed = true
+			fmt.Fprintf(report, "%v: %q not fixed: %v\n", f.Misspelling.Position(), f.Misspelling.Word, f.Err)
+		}
+	}
+	if failed {
+		return errNotApplied
+	}
+	return nil
+}
+
+// errInvalid is returned by Validate when a stream does not conform to the
+// schema.
+var errInvalid = errors.New("invalid metadata")
 
-	return fix.Fix(misspellings, errs)
+// Validate checks that the metadata streams in paths, or in STDIN if there are
+// no paths, conform to the schema, and reports each problem to STDOUT. It
+// returns errInvalid if any problem is found.
+func Validate(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	count := 0
+	for _, path := range paths {
+		var r io.Reader = os.Stdin
+		name := "STDIN"
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r, name = f, path
+		}
+		err := stream.Validate(r, func(e *stream.ValidationError) {
+			count++
+			fmt.Printf("%s:%d: %v\n", name, e.Line, e.Err)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if count > 0 {
+		return errInvalid
+	}
+	return nil
 }
@@ -1,106 +1,310 @@
 package apply
 
 import (
-	"container/heap"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/rhcarvalho/typokiller/pkg/rename"
 	"github.com/rhcarvalho/typokiller/pkg/types"
 )
 
-// Apply replaces misspelled words with their respective replacements.
-// It processes changes from bottom to the top of files to do not invalidate
-// offsets.
-func Apply(misspellings []*types.Misspelling, status chan string) {
-	// Create a priority queue, put the items in it, and
-	// establish the priority queue (heap) invariants.
-	pq := make(PriorityQueue, len(misspellings))
-	for i, m := range misspellings {
-		pq[i] = &Item{
-			value:    m,
-			priority: m.Text.Position.Offset + m.Offset,
-			index:    i,
+// Result tells the changes applied to a file.
+type Result struct {
+	Filename string
+	// Applied are the misspellings fixed in the file.
+	Applied []*types.Misspelling
+	// Failed are the misspellings that could not be fixed.
+	Failed []*Failure
+	// Edits is the number of edits made to the file, including references
+	// to renamed identifiers declared in other files.
+	Edits int
+	// Err is set if the file could not be read or written, in which case
+	// the file is unchanged.
+	Err error
+}
+
+// Failure tells why a misspelling could not be fixed.
+type Failure struct {
+	Misspelling *types.Misspelling
+	Err         error
+}
+
+// Apply replaces misspelled words with their respective replacements, and
+// returns the results by file. The replacements of each file are written at
+// once, and files are replaced atomically, keeping their permissions.
+//
+// Identifiers are renamed first, together with all references to them, and
+// the offsets of the other replacements are shifted accordingly.
+func Apply(misspellings []*types.Misspelling) []*Result {
+	results := make(resultMap)
+	var renames [][]rename.Edit
+	for _, m := range misspellings {
+		if m.Action.Type != types.Rename {
+			continue
+		}
+		r := results.get(m.Text.Position.Filename)
+		edits, err := renameIdentifier(m, renames)
+		if err != nil {
+			r.Failed = append(r.Failed, &Failure{m, err})
+			continue
+		}
+		r.Applied = append(r.Applied, m)
+		for _, edit := range edits {
+			results.get(edit.Filename).Edits++
+		}
+		renames = append(renames, edits)
+	}
+
+	for filename, misspellings := range results.replacements(misspellings) {
+		r := results[filename]
+		b, err := readFile(filename)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		reps := addReplacements(r, b, nil, misspellings, renames)
+		if len(reps) == 0 {
+			continue
 		}
+		if err := writeFile(filename, replaceAll(b, reps)); err != nil {
+			r.Err = err
+			continue
+		}
+		r.applied(reps)
 	}
-	heap.Init(&pq)
+	return results.sorted()
+}
 
-	// Take the items out; they arrive in decreasing priority order.
-	for max := len(pq); max > 0; max-- {
-		item := heap.Pop(&pq).(*Item)
-		m := item.value
+// resultMap holds results by absolute filename.
+type resultMap map[string]*Result
+
+// get returns the result of filename, adding it if needed.
+func (results resultMap) get(filename string) *Result {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	r, ok := results[filename]
+	if !ok {
+		r = &Result{Filename: filename}
+		results[filename] = r
+	}
+	return r
+}
+
+// replacements returns the misspellings to be replaced, by absolute filename.
+func (results resultMap) replacements(misspellings []*types.Misspelling) map[string][]*types.Misspelling {
+	byFile := make(map[string][]*types.Misspelling)
+	for _, m := range misspellings {
 		if m.Action.Type == types.Replace {
-			status <- "."
-			pos := m.Text.Position
+			r := results.get(m.Text.Position.Filename)
+			byFile[r.Filename] = append(byFile[r.Filename], m)
+		}
+	}
+	return byFile
+}
 
-			b, err := ioutil.ReadFile(pos.Filename)
-			if err != nil {
-				panic(err)
-			}
-			begin := pos.Offset + m.Offset
-			end := begin + len(m.Word)
-			found := string(b[begin:end])
-			if found == m.Word {
-				replaced := replaceSlice(b, begin, end, []byte(m.Action.Replacement)...)
-				ioutil.WriteFile(pos.Filename, replaced, 0644)
-			} else {
-				status <- fmt.Sprintf("(%s != %s)", found, m.Word)
-			}
+// sorted returns the results sorted by filename.
+func (results resultMap) sorted() []*Result {
+	var r []*Result
+	for _, result := range results {
+		r = append(r, result)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Filename < r[j].Filename })
+	return r
+}
+
+// applied records the replaced misspellings of reps, in file order.
+func (r *Result) applied(reps []replacement) {
+	for _, rep := range reps {
+		if rep.m.Action.Type == types.Replace {
+			r.Applied = append(r.Applied, rep.m)
+			r.Edits++
 		}
 	}
-	close(status)
 }
 
-// replaceSlice replaces part of a byte slice with a byte or slice.
-// This is similar in intent to slice assignment as implemented in Python:
-//   a[3:6] = b[1:4]
-func replaceSlice(slice []byte, begin, end int, repl ...byte) []byte {
-	total := len(slice) - (end - begin) + len(repl)
-	if total > cap(slice) {
-		newSlice := make([]byte, total)
-		copy(newSlice, slice)
-		slice = newSlice
+// replacement is a replacement of the bytes [begin, end) of a file with new.
+type replacement struct {
+	m          *types.Misspelling
+	begin, end int
+	new        string
+}
+
+// addReplacements adds to reps the replacements of misspellings in the
+// contents b of the file of r, after the given renames were done, and returns
+// reps sorted by offset. Misspellings not found at their offset, and those
+// overlapping other replacements, are recorded as failed in r.
+func addReplacements(r *Result, b []byte, reps []replacement, misspellings []*types.Misspelling, renames [][]rename.Edit) []replacement {
+	for _, m := range misspellings {
+		pos := m.Text.Position
+		begin := shift(renames, r.Filename, pos.Offset+m.Text.SourceOffset(m.Offset))
+		end := shift(renames, r.Filename, pos.Offset+m.Text.SourceOffset(m.Offset+len(m.Word)))
+		switch {
+		case pos.Offset < 0:
+			r.Failed = append(r.Failed, &Failure{m, fmt.Errorf("unknown position")})
+		case end > len(b) || string(b[begin:end]) != m.Word:
+			// the file changed since it was read
+			r.Failed = append(r.Failed, &Failure{m, fmt.Errorf("%q not found at offset %d", m.Word, begin)})
+		case overlaps(reps, begin, end):
+			r.Failed = append(r.Failed, &Failure{m, fmt.Errorf("overlaps another replacement")})
+		default:
+			reps = append(reps, replacement{m, begin, end, m.Action.Replacement})
+		}
+	}
+	sort.Slice(reps, func(i, j int) bool { return reps[i].begin < reps[j].begin })
+	return reps
+}
+
+// overlaps returns whether the bytes [begin, end) overlap any of reps.
+func overlaps(reps []replacement, begin, end int) bool {
+	for _, rep := range reps {
+		if begin < rep.end && rep.begin < end {
+			return true
+		}
 	}
-	originalLen := len(slice)
-	slice = slice[:total]
-	copy(slice[begin+len(repl):originalLen], slice[end:originalLen])
-	copy(slice[begin:begin+len(repl)], repl)
-	return slice
+	return false
 }
 
-// An Item is something we manage in a priority queue.
-type Item struct {
-	value    *types.Misspelling // The value of the item; arbitrary.
-	priority int                // The priority of the item in the queue.
-	index    int                // The index of the item in the heap.
+// replaceAll returns b with the replacements reps, sorted by offset, done,
+// leaving b unmodified.
+func replaceAll(b []byte, reps []replacement) []byte {
+	// replace from bottom to top, to keep offsets valid
+	for i := len(reps) - 1; i >= 0; i-- {
+		b = replaceSlice(b, reps[i].begin, reps[i].end, []byte(reps[i].new)...)
+	}
+	return b
 }
 
-// A PriorityQueue implements heap.Interface and holds Items.
-type PriorityQueue []*Item
+// renameIdentifier renames the identifier of m, after the given renames
+// were done, replacing the misspelled word with the replacement. Files are
+// written only if all of them can be edited.
+func renameIdentifier(m *types.Misspelling, renames [][]rename.Edit) ([]rename.Edit, error) {
+	pos := m.Text.Position
+	if pos.Offset < 0 {
+		return nil, fmt.Errorf("unknown position")
+	}
+	pos.Offset = shift(renames, pos.Filename, pos.Offset)
+	edits, err := rename.Rename(pos, newName(m), m.Action.Confirmed, buildTags(m))
+	if err != nil {
+		return nil, err
+	}
+	byFile := make(map[string][]rename.Edit)
+	for _, edit := range edits {
+		byFile[edit.Filename] = append(byFile[edit.Filename], edit)
+	}
+	contents := make(map[string][]byte)
+	for filename, edits := range byFile {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		// edits are sorted, apply them from bottom to top
+		for i := len(edits) - 1; i >= 0; i-- {
+			edit := edits[i]
+			b = replaceSlice(b, edit.Offset, edit.Offset+len(edit.Old), []byte(edit.New)...)
+		}
+		contents[filename] = b
+	}
+	for filename, b := range contents {
+		if err := writeFile(filename, b); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
+}
 
-func (pq PriorityQueue) Len() int { return len(pq) }
+// newName returns the identifier of m with the misspelled word replaced.
+func newName(m *types.Misspelling) string {
+	return m.Text.Content[:m.Offset] + m.Action.Replacement + m.Text.Content[m.Offset+len(m.Word):]
+}
 
-func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].priority > pq[j].priority
+// buildTags returns the build tags that the package of m was read with.
+func buildTags(m *types.Misspelling) []string {
+	if m.Text.Package == nil {
+		return nil
+	}
+	return m.Text.Package.BuildTags
 }
 
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
-	pq[i].index = i
-	pq[j].index = j
+// readFile reads the file filename. Texts within other files, like the
+// articles of MediaWiki dumps, are named path#name, and cannot be fixed.
+func readFile(filename string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if i := strings.LastIndex(filename, "#"); os.IsNotExist(err) && i > 0 {
+		if fi, statErr := os.Stat(filename[:i]); statErr == nil && fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is within %s, and cannot be fixed", filename[i+1:], filename[:i])
+		}
+	}
+	return b, err
 }
 
-func (pq *PriorityQueue) Push(x interface{}) {
-	n := len(*pq)
-	item := x.(*Item)
-	item.index = n
-	*pq = append(*pq, item)
+// writeFile replaces the contents of the file filename with b atomically,
+// writing b to a temporary file in the same directory and renaming it over the
+// file. The permissions of the file are kept, and symbolic links are followed.
+func writeFile(filename string, b []byte) error {
+	filename, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Chmod(fi.Mode())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
 }
 
-func (pq *PriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	item.index = -1 // for safety
-	*pq = old[0 : n-1]
-	return item
+// shift returns the offset in filename after the given renames were done,
+// in order.
+func shift(renames [][]rename.Edit, filename string, offset int) int {
+	if abs, err := filepath.Abs(filename); err == nil {
+		// renames edit files by absolute path
+		filename = abs
+	}
+	for _, edits := range renames {
+		delta := 0
+		for _, edit := range edits {
+			if edit.Filename == filename && edit.Offset < offset {
+				delta += len(edit.New) - len(edit.Old)
+			}
+		}
+		offset += delta
+	}
+	return offset
+}
+
+// replaceSlice returns a new slice with the bytes [begin, end) of slice
+// replaced with repl. This is similar in intent to slice assignment as
+// implemented in Python:
+//
+//	a[3:6] = b[1:4]
+func replaceSlice(slice []byte, begin, end int, repl ...byte) []byte {
+	out := make([]byte, 0, len(slice)-(end-begin)+len(repl))
+	out = append(out, slice[:begin]...)
+	out = append(out, repl...)
+	return append(out, slice[end:]...)
 }
@@ -0,0 +1,232 @@
+package apply
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+const (
+	script = "#!/bin/sh\n# recieve the lenght\necho teh end\n"
+	goMod  = "module example.com/m\n"
+	goFile = `package m
+
+// count the lenght of a recieve buffer.
+func count(lenght int) int { return lenght }
+`
+)
+
+// writeFiles writes files, by name, to a temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// misspelling returns a misspelling of the first occurrence of word after s in
+// the content of file name in dir, to be replaced with replacement.
+func misspelling(dir, name, content, s, word, replacement string) *types.Misspelling {
+	offset := strings.Index(content, s)
+	return &types.Misspelling{
+		Word:   word,
+		Offset: strings.Index(s, word),
+		Action: types.Action{Type: types.Replace, Replacement: replacement},
+		Text: &types.Text{
+			Content:  s,
+			Position: token.Position{Filename: filepath.Join(dir, name), Offset: offset},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"script.sh": script})
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "script.sh")
+	if err := os.Chmod(filename, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := misspelling(dir, "script.sh", script, "echo teh end", "teh", "the")
+	stale.Offset = 0
+	overlapping := misspelling(dir, "script.sh", script, "recieve the lenght", "recieve the", "receive a")
+	misspellings := []*types.Misspelling{
+		misspelling(dir, "script.sh", script, "echo teh end", "teh", "the"),
+		misspelling(dir, "script.sh", script, "recieve the lenght", "recieve", "receive"),
+		misspelling(dir, "script.sh", script, "recieve the lenght", "lenght", "length"),
+		stale,
+		overlapping,
+		misspelling(dir, "missing.sh", script, "echo teh end", "teh", "the"),
+	}
+	results := Apply(misspellings)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+
+	missing := results[0]
+	if missing.Filename != filepath.Join(dir, "missing.sh") || !os.IsNotExist(missing.Err) {
+		t.Errorf("result for missing file = %+v, want not exist error", missing)
+	}
+
+	r := results[1]
+	if r.Filename != filename || r.Err != nil {
+		t.Fatalf("result = %+v, want %s with no error", r, filename)
+	}
+	if r.Edits != 3 || len(r.Applied) != 3 {
+		t.Errorf("result has %d edits and %d applied, want 3", r.Edits, len(r.Applied))
+	}
+	for i, m := range r.Applied {
+		if m != misspellings[[]int{1, 2, 0}[i]] {
+			t.Errorf("Applied[%d] = %q, want misspellings in file order", i, m.Word)
+		}
+	}
+	if len(r.Failed) != 2 {
+		t.Fatalf("got %d failures, want 2", len(r.Failed))
+	}
+	for _, f := range r.Failed {
+		if f.Misspelling != stale && f.Misspelling != overlapping {
+			t.Errorf("%q failed: %v", f.Misspelling.Word, f.Err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#!/bin/sh\n# receive the length\necho the end\n"
+	if string(b) != want {
+		t.Errorf("file has %q, want %q", b, want)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Errorf("file mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0755))
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files, want only the script, no temporary file left", len(files))
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"go.mod": goMod, "m.go": goFile})
+	defer os.RemoveAll(dir)
+
+	rename := misspelling(dir, "m.go", goFile, "lenght int", "lenght", "length")
+	rename.Text.Content = "lenght"
+	rename.Text.Identifier = true
+	rename.Action.Type = types.Rename
+	misspellings := []*types.Misspelling{
+		rename,
+		misspelling(dir, "m.go", goFile, "the lenght of a recieve", "recieve", "receive"),
+		misspelling(dir, "m.go", goFile, "count(lenght", "count", "Count"),
+	}
+	results := Apply(misspellings)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Err != nil || len(r.Failed) != 0 {
+		t.Fatalf("result = %+v, want no errors", r)
+	}
+	if r.Edits != 4 || len(r.Applied) != 3 {
+		t.Errorf("result has %d edits and %d applied, want 4 and 3", r.Edits, len(r.Applied))
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "m.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer("count(lenght", "Count(length", "recieve", "receive", "return lenght", "return length").Replace(goFile)
+	if string(b) != want {
+		t.Errorf("file has %q, want %q", b, want)
+	}
+}
+
+func TestApplyLength(t *testing.T) {
+	const text = "They live in burows, okk.\nEnd of file.\n"
+	dir := writeFiles(t, map[string]string{"text.txt": text})
+	defer os.RemoveAll(dir)
+
+	results := Apply([]*types.Misspelling{
+		// longer and shorter than the misspelled words
+		misspelling(dir, "text.txt", text, "burows, okk", "burows", "burrows"),
+		misspelling(dir, "text.txt", text, "burows, okk", "okk", "ok"),
+	})
+	if len(results) != 1 || results[0].Err != nil || len(results[0].Failed) != 0 {
+		t.Fatalf("results = %+v, want no errors", results)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "They live in burrows, ok.\nEnd of file.\n"; string(b) != want {
+		t.Errorf("file has %q, want %q", b, want)
+	}
+}
+
+func TestApplyRenameLength(t *testing.T) {
+	const src = `package m
+
+// count the lenth of a buffer.
+func count(lenth int) int { return lenth }
+
+// total is the counnt of a recieve buffer.
+var total = count(1)
+`
+	dir := writeFiles(t, map[string]string{"go.mod": goMod, "m.go": src})
+	defer os.RemoveAll(dir)
+
+	rename := misspelling(dir, "m.go", src, "lenth int", "lenth", "length")
+	rename.Text.Content = "lenth"
+	rename.Text.Identifier = true
+	rename.Action.Type = types.Rename
+	results := Apply([]*types.Misspelling{
+		rename,
+		misspelling(dir, "m.go", src, "the lenth of", "lenth", "length"),
+		// after the renamed identifier, shifted by the longer name
+		misspelling(dir, "m.go", src, "the counnt of a recieve", "counnt", "count"),
+		misspelling(dir, "m.go", src, "the counnt of a recieve", "recieve", "receive"),
+	})
+	if len(results) != 1 || results[0].Err != nil || len(results[0].Failed) != 0 {
+		t.Fatalf("results = %+v, want no errors", results)
+	}
+	if r := results[0]; r.Edits != 5 || len(r.Applied) != 4 {
+		t.Errorf("result has %d edits and %d applied, want 5 and 4", r.Edits, len(r.Applied))
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "m.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer("lenth", "length", "counnt", "count", "recieve", "receive").Replace(src)
+	if string(b) != want {
+		t.Errorf("file has %q, want %q", b, want)
+	}
+}
+
+func TestApplyWithinFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"dump.xml": script})
+	defer os.RemoveAll(dir)
+
+	results := Apply([]*types.Misspelling{
+		misspelling(dir, "dump.xml#Gopher", script, "echo teh end", "teh", "the"),
+	})
+	if len(results) != 1 || results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "cannot be fixed") {
+		t.Errorf("results = %+v, want an error for a text within a file", results)
+	}
+}
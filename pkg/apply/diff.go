@@ -0,0 +1,226 @@
+package apply
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/rename"
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// contextLines is the number of unchanged lines around changes in diffs.
+const contextLines = 3
+
+// Diff writes to w the changes that Apply would make as a unified diff, with
+// paths relative to the working directory, as expected by git apply and patch
+// -p1. It changes no file, and returns the results by file that Apply would,
+// save for errors writing files. Files outside of the working directory cannot
+// be patched from it, and their results have an error instead.
+//
+// All identifiers are renamed from the files as they are, so conflicts between
+// two renames are only found by Apply.
+func Diff(w io.Writer, misspellings []*types.Misspelling) ([]*Result, error) {
+	results := make(resultMap)
+	edits := make(map[string][]replacement)
+	for _, m := range misspellings {
+		if m.Action.Type != types.Rename {
+			continue
+		}
+		r := results.get(m.Text.Position.Filename)
+		if err := addRename(edits, m); err != nil {
+			r.Failed = append(r.Failed, &Failure{m, err})
+			continue
+		}
+		r.Applied = append(r.Applied, m)
+	}
+	for filename, reps := range edits {
+		results.get(filename).Edits += len(reps)
+	}
+
+	byFile := results.replacements(misspellings)
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(w)
+	sorted := results.sorted()
+	for _, r := range sorted {
+		if len(byFile[r.Filename]) == 0 && len(edits[r.Filename]) == 0 {
+			continue
+		}
+		name, err := filepath.Rel(cwd, r.Filename)
+		if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			r.Err = fmt.Errorf("not in the working directory %s", cwd)
+			continue
+		}
+		b, err := readFile(r.Filename)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		reps := addReplacements(r, b, edits[r.Filename], byFile[r.Filename], nil)
+		r.applied(reps)
+		if len(reps) == 0 {
+			continue
+		}
+		writeDiff(bw, filepath.ToSlash(name), b, reps)
+	}
+	return sorted, bw.Flush()
+}
+
+// addRename adds to edits, by filename, the replacements that rename the
+// identifier of m, unless they overlap others.
+func addRename(edits map[string][]replacement, m *types.Misspelling) error {
+	pos := m.Text.Position
+	if pos.Offset < 0 {
+		return fmt.Errorf("unknown position")
+	}
+	renameEdits, err := rename.Rename(pos, newName(m), m.Action.Confirmed, buildTags(m))
+	if err != nil {
+		return err
+	}
+	for _, edit := range renameEdits {
+		if overlaps(edits[edit.Filename], edit.Offset, edit.Offset+len(edit.Old)) {
+			return fmt.Errorf("overlaps another replacement")
+		}
+	}
+	for _, edit := range renameEdits {
+		rep := replacement{m, edit.Offset, edit.Offset + len(edit.Old), edit.New}
+		edits[edit.Filename] = append(edits[edit.Filename], rep)
+	}
+	return nil
+}
+
+// change is a run of lines [first, last] of a file changed by replacements.
+// Adjacent lines are in the same change.
+type change struct {
+	first, last int
+	reps        []replacement
+}
+
+// writeDiff writes the replacements reps, sorted by offset, of the contents b
+// of the file name as a unified diff.
+func writeDiff(w *bufio.Writer, name string, b []byte, reps []replacement) {
+	// offsets of the beginning of each line, and of the end of b
+	starts := []int{0}
+	for i, c := range b {
+		if c == '\n' && i+1 < len(b) {
+			starts = append(starts, i+1)
+		}
+	}
+	n := len(starts)
+	starts = append(starts, len(b))
+	line := func(offset int) int {
+		return sort.Search(n, func(i int) bool { return starts[i] > offset }) - 1
+	}
+
+	var changes []*change
+	for _, rep := range reps {
+		first, last := line(rep.begin), line(rep.end-1)
+		if k := len(changes) - 1; k >= 0 && first <= changes[k].last+1 {
+			changes[k].last = last
+			changes[k].reps = append(changes[k].reps, rep)
+			continue
+		}
+		changes = append(changes, &change{first, last, []replacement{rep}})
+	}
+
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name)
+	delta := 0 // lines added by earlier hunks
+	for len(changes) > 0 {
+		// changes with few lines between them share a hunk
+		k := 1
+		for k < len(changes) && changes[k].first-changes[k-1].last-1 <= 2*contextLines {
+			k++
+		}
+		hunk := changes[:k]
+		changes = changes[k:]
+
+		begin, end := hunk[0].first-contextLines, hunk[k-1].last+1+contextLines
+		if begin < 0 {
+			begin = 0
+		}
+		if end > n {
+			end = n
+		}
+		var lines [][]byte // with prefixes
+		context := func(from, to int) {
+			for i := from; i < to; i++ {
+				lines = append(lines, prefixed(' ', b[starts[i]:starts[i+1]]))
+			}
+		}
+		oldLen, newLen := end-begin, end-begin
+		context(begin, hunk[0].first)
+		for i, c := range hunk {
+			if i > 0 {
+				context(hunk[i-1].last+1, c.first)
+			}
+			old := b[starts[c.first]:starts[c.last+1]]
+			for _, l := range splitLines(old) {
+				lines = append(lines, prefixed('-', l))
+			}
+			reps := make([]replacement, len(c.reps))
+			for j, rep := range c.reps {
+				rep.begin -= starts[c.first]
+				rep.end -= starts[c.first]
+				reps[j] = rep
+			}
+			added := splitLines(replaceAll(old, reps))
+			for _, l := range added {
+				lines = append(lines, prefixed('+', l))
+			}
+			newLen += len(added) - (c.last - c.first + 1)
+		}
+		context(hunk[k-1].last+1, end)
+
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(begin, oldLen), hunkRange(begin+delta, newLen))
+		for _, l := range lines {
+			w.Write(l)
+		}
+		delta += newLen - oldLen
+	}
+}
+
+// prefixed returns line with prefix, as a line of a hunk.
+func prefixed(prefix byte, line []byte) []byte {
+	l := append([]byte{prefix}, line...)
+	if line[len(line)-1] != '\n' {
+		l = append(l, "\n\\ No newline at end of file\n"...)
+	}
+	return l
+}
+
+// splitLines splits b after each newline.
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	for len(b) > 0 {
+		i := len(b)
+		for j, c := range b {
+			if c == '\n' {
+				i = j + 1
+				break
+			}
+		}
+		lines = append(lines, b[:i])
+		b = b[i:]
+	}
+	return lines
+}
+
+// hunkRange formats the range of n lines starting at line start, counted from
+// 0, in a hunk header.
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		// the line before an empty range
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
@@ -0,0 +1,164 @@
+package apply
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+const text = `line one
+teh second
+three
+four
+five
+six
+seven
+eight
+nine
+ten
+eleven
+twelve
+thirteen
+last is teh end`
+
+// chdir changes the working directory to dir, returning a function to
+// restore it.
+func chdir(t *testing.T, dir string) func() {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"text.txt": text})
+	defer os.RemoveAll(dir)
+	defer chdir(t, dir)()
+
+	stale := misspelling(dir, "text.txt", text, "three", "three", "3")
+	stale.Word = "tree"
+	misspellings := []*types.Misspelling{
+		misspelling(dir, "text.txt", text, "last is teh end", "teh", "the"),
+		misspelling(dir, "text.txt", text, "teh second", "teh", "the"),
+		misspelling(dir, "text.txt", text, "five", "five", "five\nhalf"),
+		stale,
+	}
+	var diff bytes.Buffer
+	results, err := Diff(&diff, misspellings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a/text.txt
++++ b/text.txt
+@@ -1,8 +1,9 @@
+ line one
+-teh second
++the second
+ three
+ four
+-five
++five
++half
+ six
+ seven
+ eight
+@@ -11,4 +12,4 @@
+ eleven
+ twelve
+ thirteen
+-last is teh end
+\ No newline at end of file
++last is the end
+\ No newline at end of file
+`
+	if diff.String() != want {
+		t.Errorf("got diff:\n%s\nwant:\n%s", diff.String(), want)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Edits != 3 || len(r.Applied) != 3 || len(r.Failed) != 1 || r.Failed[0].Misspelling != stale {
+		t.Errorf("result = %+v, want 3 edits and the stale misspelling failed", r)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != text {
+		t.Errorf("file changed to %q", b)
+	}
+}
+
+func TestDiffRename(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"go.mod": goMod, "m.go": goFile})
+	defer os.RemoveAll(dir)
+	defer chdir(t, dir)()
+
+	rename := misspelling(dir, "m.go", goFile, "lenght int", "lenght", "length")
+	rename.Text.Content = "lenght"
+	rename.Text.Identifier = true
+	rename.Action.Type = types.Rename
+	misspellings := []*types.Misspelling{
+		rename,
+		misspelling(dir, "m.go", goFile, "the lenght of a recieve", "recieve", "receive"),
+		misspelling(dir, "m.go", goFile, "count(lenght", "count", "Count"),
+	}
+	var diff bytes.Buffer
+	results, err := Diff(&diff, misspellings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a/m.go\n" +
+		"+++ b/m.go\n" +
+		"@@ -1,4 +1,4 @@\n" +
+		" package m\n" +
+		" \n" +
+		"-// count the lenght of a recieve buffer.\n" +
+		"-func count(lenght int) int { return lenght }\n" +
+		"+// count the lenght of a receive buffer.\n" +
+		"+func Count(length int) int { return length }\n"
+	if diff.String() != want {
+		t.Errorf("got diff:\n%s\nwant:\n%s", diff.String(), want)
+	}
+	if len(results) != 1 || results[0].Edits != 4 || len(results[0].Applied) != 3 {
+		t.Errorf("results = %+v, want 4 edits and 3 misspellings applied in m.go", results)
+	}
+}
+
+func TestDiffOutside(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"text.txt": text})
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer chdir(t, sub)()
+
+	var diff bytes.Buffer
+	results, err := Diff(&diff, []*types.Misspelling{
+		misspelling(dir, "text.txt", text, "teh second", "teh", "the"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Len() != 0 {
+		t.Errorf("got diff of a file outside of the working directory:\n%s", diff.String())
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("results = %+v, want an error for the file outside of the working directory", results)
+	}
+}
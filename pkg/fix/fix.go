@@ -1,7 +1,9 @@
 package fix
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"unicode"
@@ -14,19 +16,13 @@ import (
 
 // Fix turns the terminal into an interactive UI for fixing typos.
 func Fix(misspellings <-chan *types.Misspelling, errs <-chan error) error {
-	ui := NewUI()
-
-	// read misspellings channel in a goroutine
-	go func() {
-		for misspelling := range misspellings {
-			ui.Misspellings = append(ui.Misspellings, misspelling)
-			ui.Draw()
-		}
-		ui.DoneLoadingInput = true
-		ui.Draw()
-	}()
+	return NewUI().Fix(misspellings, errs)
+}
 
-	// block this goroutine in the UI mainloop
+// Fix turns the terminal into ui, adding misspellings to it as they are
+// received.
+func (ui *UI) Fix(misspellings <-chan *types.Misspelling, errs <-chan error) error {
+	ui.loading = misspellings
 	return ui.Mainloop(errs)
 }
 
@@ -36,6 +32,28 @@ type UI struct {
 	Index            int
 	Printer          *print.TermboxPrinter
 	DoneLoadingInput bool
+	// DryRun, if not nil, is where the changes of the last Apply are
+	// written as a unified diff when Mainloop returns, instead of changing
+	// files, so that the diff is not mixed with the UI on the terminal.
+	DryRun io.Writer
+	// SessionPath, if set, is the file where the state of the UI is saved
+	// after each action, to be resumed later.
+	SessionPath string
+	// Hashes are the SHA-256 hashes of the source files of Misspellings, by
+	// filename, when they were loaded.
+	Hashes map[string]string
+	// Stale are the misspellings whose source files changed since they were
+	// loaded, by Apply or, in a resumed session, elsewhere.
+	Stale map[*types.Misspelling]bool
+
+	// loading receives the misspellings added to the UI in Mainloop, so
+	// that the state of the UI is only used by one goroutine.
+	loading <-chan *types.Misspelling
+	// applied tells that the last action applied all changes, so that the
+	// session is no longer needed.
+	applied bool
+	// diff is the unified diff of the last Apply in a dry run.
+	diff []byte
 }
 
 // NewUI creates a new UI.
@@ -47,12 +65,20 @@ func NewUI() *UI {
 }
 
 // Mainloop draws the current state in the terminal and waits for user input.
-func (ui *UI) Mainloop(errs <-chan error) error {
+// The state is saved to SessionPath after each input, and removed when
+// quitting right after all changes were applied. In a dry run, the diff of
+// the last Apply is written to DryRun once the terminal is restored.
+func (ui *UI) Mainloop(errs <-chan error) (err error) {
 	// initialize termbox
-	err := termbox.Init()
+	err = termbox.Init()
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if werr := ui.writeDiff(); err == nil {
+			err = werr
+		}
+	}()
 	defer termbox.Close()
 	termbox.HideCursor()
 	termbox.SetOutputMode(termbox.Output256)
@@ -72,12 +98,24 @@ func (ui *UI) Mainloop(errs <-chan error) error {
 			if ok {
 				return err
 			}
+			errs = nil
+		case m, ok := <-ui.loading:
+			if !ok {
+				ui.loading = nil
+				ui.DoneLoadingInput = true
+			} else {
+				ui.hashFile(m.Text.Position.Filename)
+				ui.Misspellings = append(ui.Misspellings, m)
+			}
+			ui.Draw()
 		case ev := <-events:
+			applied := ui.applied
+			ui.applied = false
 			switch ev.Type {
 			case termbox.EventKey:
 				switch ev.Key {
 				case termbox.KeyEsc:
-					return nil
+					return ui.quit(applied)
 				case termbox.KeyArrowUp, termbox.KeyArrowRight:
 					ui.Next()
 				case termbox.KeyArrowDown, termbox.KeyArrowLeft:
@@ -98,20 +136,34 @@ func (ui *UI) Mainloop(errs <-chan error) error {
 						ui.EditAll()
 					case 'n':
 						ui.NextUndefined()
+					case 'd':
+						ui.Diff()
 					case 'a':
 						ui.Apply()
 					case 'q':
-						return nil
+						return ui.quit(applied)
 					}
 				}
 			case termbox.EventError:
 				return ev.Err
 			}
 			ui.Draw()
+			if err := ui.SaveSession(); err != nil {
+				return fmt.Errorf("saving session: %v", err)
+			}
 		}
 	}
 }
 
+// quit saves the session before quitting or, if all changes were applied,
+// removes it.
+func (ui *UI) quit(applied bool) error {
+	if !applied {
+		return ui.SaveSession()
+	}
+	return ui.RemoveSession()
+}
+
 // Write implements the io.Writer interface.
 func (ui *UI) Write(p []byte) (int, error) {
 	return ui.Printer.Write(p)
@@ -163,19 +215,20 @@ func (ui *UI) Ignore() {
 // Replace replaces the current misspell with a suggestion.
 func (ui *UI) Replace() {
 	m := ui.Misspellings[ui.Index]
-	m.Action = types.Action{
-		Type:        types.Replace,
-		Replacement: m.Suggestions[ui.ReadIntegerInRange(1, len(m.Suggestions))-1]}
-	ui.NextUndefined()
+	replacement := m.Suggestions[ui.ReadIntegerInRange(1, len(m.Suggestions))-1]
+	if action, ok := ui.replaceAction(m, replacement, ui.confirmOnce()); ok {
+		m.Action = action
+		ui.NextUndefined()
+	}
 }
 
 // Edit replaces the current misspell with custom text.
 func (ui *UI) Edit() {
 	m := ui.Misspellings[ui.Index]
-	m.Action = types.Action{
-		Type:        types.Replace,
-		Replacement: ui.ReadString()}
-	ui.NextUndefined()
+	if action, ok := ui.replaceAction(m, ui.ReadString(), ui.confirmOnce()); ok {
+		m.Action = action
+		ui.NextUndefined()
+	}
 }
 
 // IgnoreAll ignores all misspells with Undefined action that matches the
@@ -197,10 +250,13 @@ func (ui *UI) ReplaceAll() {
 	m := ui.Misspellings[ui.Index]
 	word := m.Word
 	replacement := m.Suggestions[ui.ReadIntegerInRange(1, len(m.Suggestions))-1]
+	confirm := ui.confirmOnce()
 	for i := ui.Index; i < len(ui.Misspellings); i++ {
 		m = ui.Misspellings[i]
 		if m.Word == word && m.Action.Type == types.Undefined {
-			m.Action = types.Action{Type: types.Replace, Replacement: replacement}
+			if action, ok := ui.replaceAction(m, replacement, confirm); ok {
+				m.Action = action
+			}
 		}
 	}
 	ui.NextUndefined()
@@ -211,38 +267,164 @@ func (ui *UI) EditAll() {
 	m := ui.Misspellings[ui.Index]
 	word := m.Word
 	replacement := ui.ReadString()
+	confirm := ui.confirmOnce()
 	for i := ui.Index; i < len(ui.Misspellings); i++ {
 		m = ui.Misspellings[i]
 		if m.Word == word && m.Action.Type == types.Undefined {
-			m.Action = types.Action{Type: types.Replace, Replacement: replacement}
+			if action, ok := ui.replaceAction(m, replacement, confirm); ok {
+				m.Action = action
+			}
 		}
 	}
 	ui.NextUndefined()
 }
 
-// Apply applies marked changes to disk.
+// replaceAction returns the action that replaces the misspelled word of m with
+// replacement. Misspelled identifiers are renamed, together with all references
+// to them. Renaming exported identifiers changes the API of their package, and
+// it returns false unless confirm returns true.
+func (ui *UI) replaceAction(m *types.Misspelling, replacement string, confirm func() bool) (types.Action, bool) {
+	if !m.Text.Identifier {
+		return types.Action{Type: types.Replace, Replacement: replacement}, true
+	}
+	if m.Text.Exported && !confirm() {
+		return types.Action{}, false
+	}
+	return types.Action{Type: types.Rename, Replacement: replacement, Confirmed: m.Text.Exported}, true
+}
+
+// confirmOnce returns a function that asks the user to confirm renaming
+// exported identifiers the first time it is called, and returns the same
+// answer afterwards.
+func (ui *UI) confirmOnce() func() bool {
+	var asked, confirmed bool
+	return func() bool {
+		if !asked {
+			asked = true
+			confirmed = ui.ReadConfirmation("rename exported identifier, changing the package API?")
+		}
+		return confirmed
+	}
+}
+
+// Apply applies marked changes to disk, or writes them to DryRun.
 func (ui *UI) Apply() {
 	defer termbox.PollEvent() // stay visible until user presses a key
 	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 	ui.DrawBorders()
 	ui.Printer.Reset()
 	ui.Printer.SetForeground(termbox.ColorGreen)
-	fmt.Fprintln(ui, "applying changes")
+	if ui.DryRun != nil {
+		fmt.Fprintln(ui, "writing changes as a diff")
+	} else {
+		fmt.Fprintln(ui, "applying changes")
+	}
 	termbox.Flush()
 
-	status := make(chan string)
-	go apply.Apply(ui.Misspellings, status)
+	results, err := ui.apply()
+	if err != nil {
+		ui.Printer.SetForeground(termbox.ColorRed)
+		fmt.Fprintln(ui, err)
+	}
+	ui.DrawResults(results)
+	ui.Printer.SetForeground(termbox.ColorGreen)
+	fmt.Fprint(ui, "done")
+	ui.Printer.ResetColors()
+	termbox.Flush()
+}
 
-	ui.Printer.SetForeground(termbox.ColorYellow)
-	for s := range status {
-		fmt.Fprint(ui, s)
-		termbox.Flush()
+// apply applies marked changes to disk or, in a dry run, keeps them as a
+// unified diff, replacing the diff of previous calls.
+func (ui *UI) apply() ([]*apply.Result, error) {
+	if ui.DryRun != nil {
+		var diff bytes.Buffer
+		results, err := apply.Diff(&diff, ui.Misspellings)
+		if err != nil {
+			ui.diff = nil
+			return results, err
+		}
+		ui.diff = diff.Bytes()
+		return results, nil
+	}
+	results := apply.Apply(ui.Misspellings)
+	ui.forget(results)
+	ui.applied = true
+	for _, r := range results {
+		if r.Err != nil || len(r.Failed) > 0 {
+			ui.applied = false
+		}
+	}
+	return results, nil
+}
+
+// writeDiff writes the diff of the last Apply in a dry run to DryRun.
+func (ui *UI) writeDiff() error {
+	if ui.diff == nil {
+		return nil
+	}
+	_, err := ui.DryRun.Write(ui.diff)
+	return err
+}
+
+// Diff shows the changes that Apply would make as a unified diff, as much of
+// it as fits in the screen.
+func (ui *UI) Diff() {
+	defer termbox.PollEvent() // stay visible until user presses a key
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	ui.DrawBorders()
+	ui.Printer.Reset()
+
+	var diff bytes.Buffer
+	results, err := apply.Diff(&diff, ui.Misspellings)
+	if err != nil {
+		ui.Printer.SetForeground(termbox.ColorRed)
+		fmt.Fprintln(ui, err)
+	}
+	for _, line := range strings.SplitAfter(diff.String(), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			ui.Printer.SetForeground(termbox.ColorDefault | termbox.AttrBold)
+		case strings.HasPrefix(line, "+"):
+			ui.Printer.SetForeground(termbox.ColorGreen)
+		case strings.HasPrefix(line, "-"):
+			ui.Printer.SetForeground(termbox.ColorRed)
+		case strings.HasPrefix(line, "@@"):
+			ui.Printer.SetForeground(termbox.ColorCyan)
+		default:
+			ui.Printer.ResetColors()
+		}
+		fmt.Fprint(ui, line)
+	}
+	for _, r := range results {
+		if r.Err != nil || len(r.Failed) > 0 {
+			ui.Printer.NewLine()
+			ui.DrawResults(results)
+			break
+		}
 	}
-	fmt.Fprint(ui, "\ndone")
 	ui.Printer.ResetColors()
 	termbox.Flush()
 }
 
+// DrawResults draws the number of edits of each file, and the misspellings
+// that cannot be fixed.
+func (ui *UI) DrawResults(results []*apply.Result) {
+	for _, r := range results {
+		if r.Err != nil {
+			ui.Printer.SetForeground(termbox.ColorRed)
+			fmt.Fprintf(ui, "%s: %v\n", r.Filename, r.Err)
+		} else {
+			ui.Printer.SetForeground(termbox.ColorYellow)
+			fmt.Fprintf(ui, "%s: %d edits\n", r.Filename, r.Edits)
+		}
+		ui.Printer.SetForeground(termbox.ColorRed)
+		for _, f := range r.Failed {
+			fmt.Fprintf(ui, "  %q not fixed: %v\n", f.Misspelling.Word, f.Err)
+		}
+	}
+	ui.Printer.ResetColors()
+}
+
 // ReadIntegerInRange interactively reads an integer within the range [a, b].
 func (ui *UI) ReadIntegerInRange(a, b int) int {
 start:
@@ -279,6 +461,27 @@ mainloop:
 	return i
 }
 
+// ReadConfirmation interactively asks a yes or no question, defaulting to no.
+func (ui *UI) ReadConfirmation(question string) bool {
+	ui.Printer.SetForeground(ui.Printer.Foreground() | termbox.AttrBold)
+	fmt.Fprintf(ui, "\n%s [y/N]: ", question)
+	ui.Printer.ResetColors()
+	termbox.Flush()
+	for {
+		switch ev := termbox.PollEvent(); ev.Type {
+		case termbox.EventKey:
+			switch {
+			case ev.Ch == 'y' || ev.Ch == 'Y':
+				return true
+			case ev.Ch == 'n' || ev.Ch == 'N' || ev.Key == termbox.KeyEnter || ev.Key == termbox.KeyEsc:
+				return false
+			}
+		case termbox.EventError:
+			panic(ev.Err)
+		}
+	}
+}
+
 // ReadString interactively reads an arbitrary string.
 func (ui *UI) ReadString() string {
 	ui.Printer.SetForeground(ui.Printer.Foreground() | termbox.AttrBold)
@@ -353,7 +556,14 @@ func (ui *UI) Draw() {
 
 	tp.SkipLines(2)
 	tp.Underline()
-	fmt.Fprintf(ui, "%s:%d:%d\n", text.Position.Filename, text.Position.Line, text.Position.Column)
+	fmt.Fprintf(ui, "%s:%d:%d", text.Position.Filename, text.Position.Line, text.Position.Column)
+	tp.ResetColors()
+	if ui.Stale[m] {
+		tp.SetForeground(termbox.ColorYellow)
+		fmt.Fprint(ui, " (stale: the file changed since the misspelling was found)")
+		tp.ResetColors()
+	}
+	fmt.Fprintln(ui)
 
 	tp.SkipLines(1)
 	tmp := tp.Y
@@ -408,6 +618,10 @@ func (ui *UI) Draw() {
 	tp.ResetColors()
 	fmt.Fprint(ui, "ext undefined, ")
 	tp.SetForeground(tp.Foreground() | termbox.AttrUnderline)
+	fmt.Fprint(ui, "d")
+	tp.ResetColors()
+	fmt.Fprint(ui, "iff, ")
+	tp.SetForeground(tp.Foreground() | termbox.AttrUnderline)
 	fmt.Fprint(ui, "a")
 	tp.ResetColors()
 	fmt.Fprint(ui, "pply, ")
@@ -424,6 +638,8 @@ func (ui *UI) Draw() {
 			fmt.Fprintln(ui, "ignored")
 		case types.Replace:
 			fmt.Fprintf(ui, "replace with '%s'\n", m.Action.Replacement)
+		case types.Rename:
+			fmt.Fprintf(ui, "rename replacing with '%s'\n", m.Action.Replacement)
 		}
 		tp.ResetColors()
 	}
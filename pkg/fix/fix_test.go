@@ -0,0 +1,63 @@
+package fix
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestApplyDryRunTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	filename := filepath.Join(dir, "text.txt")
+	if err := ioutil.WriteFile(filename, []byte("teh end\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	text := &types.Text{
+		Content:  "teh end",
+		Position: token.Position{Filename: filename, Offset: 0, Line: 1, Column: 1},
+	}
+	m := &types.Misspelling{Word: "teh", Text: text, Action: types.Action{Type: types.Replace, Replacement: "the"}}
+	text.Misspellings = []*types.Misspelling{m}
+	var out bytes.Buffer
+	ui := NewUI()
+	ui.DryRun = &out
+	ui.Misspellings = []*types.Misspelling{m}
+	for i := 0; i < 2; i++ {
+		if _, err := ui.apply(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %q before Mainloop returned", out.String())
+	}
+	if err := ui.writeDiff(); err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a/text.txt
++++ b/text.txt
+@@ -1 +1 @@
+-teh end
++the end
+`
+	if out.String() != want {
+		t.Errorf("got diff:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
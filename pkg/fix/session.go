@@ -0,0 +1,207 @@
+package fix
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/rhcarvalho/typokiller/pkg/apply"
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// SessionVersion is the version of the format of session files.
+const SessionVersion = 1
+
+// Session is the state of a UI, saved to a file to resume fixing later.
+type Session struct {
+	Version int
+	// Index is the index of the current misspelling.
+	Index int
+	// Hashes are the SHA-256 hashes of the source files of the misspellings,
+	// by filename, when they were loaded.
+	Hashes map[string]string
+	// Packages hold the misspellings and the actions taken on them.
+	Packages []*types.Package
+}
+
+// Packages returns the packages of misspellings, in order.
+func Packages(misspellings []*types.Misspelling) []*types.Package {
+	var pkgs []*types.Package
+	seen := make(map[*types.Package]bool)
+	for _, m := range misspellings {
+		if pkg := m.Text.Package; !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// hashFile records the hash of filename, if it is not known yet.
+func (ui *UI) hashFile(filename string) {
+	if _, ok := ui.Hashes[filename]; ok {
+		return
+	}
+	if ui.Hashes == nil {
+		ui.Hashes = make(map[string]string)
+	}
+	ui.Hashes[filename] = fileHash(filename)
+}
+
+// forget drops the misspellings fixed by Apply, and marks the others in the
+// files it wrote as stale, as their offsets may have shifted. The hashes of
+// the files are kept, so that a resumed session marks them stale too.
+func (ui *UI) forget(results []*apply.Result) {
+	fixed := make(map[*types.Misspelling]bool)
+	written := make(map[string]bool)
+	for _, r := range results {
+		if r.Err != nil || r.Edits == 0 {
+			continue
+		}
+		written[r.Filename] = true
+		for _, m := range r.Applied {
+			fixed[m] = true
+		}
+	}
+	if len(written) == 0 {
+		return
+	}
+	if ui.Stale == nil {
+		ui.Stale = make(map[*types.Misspelling]bool)
+	}
+	var kept []*types.Misspelling
+	index := ui.Index
+	for i, m := range ui.Misspellings {
+		if fixed[m] {
+			if i < ui.Index {
+				index--
+			}
+			delete(ui.Stale, m)
+			continue
+		}
+		kept = append(kept, m)
+		abs, err := filepath.Abs(m.Text.Position.Filename)
+		if err == nil && written[abs] {
+			ui.Stale[m] = true
+		}
+	}
+	for _, pkg := range Packages(ui.Misspellings) {
+		for _, text := range pkg.Documentation {
+			var left []*types.Misspelling
+			for _, m := range text.Misspellings {
+				if !fixed[m] {
+					left = append(left, m)
+				}
+			}
+			text.Misspellings = left
+		}
+	}
+	ui.Misspellings = kept
+	if index >= len(kept) {
+		index = len(kept) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	ui.Index = index
+}
+
+// fileHash returns the SHA-256 hash of the file filename in hex, or "" if it
+// cannot be read.
+func fileHash(filename string) string {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveSession writes the state of ui to the file SessionPath, if set,
+// replacing it atomically.
+func (ui *UI) SaveSession() error {
+	if ui.SessionPath == "" {
+		return nil
+	}
+	b, err := json.Marshal(&Session{
+		Version:  SessionVersion,
+		Index:    ui.Index,
+		Hashes:   ui.Hashes,
+		Packages: Packages(ui.Misspellings),
+	})
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(ui.SessionPath), "."+filepath.Base(ui.SessionPath)+".")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), ui.SessionPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// RemoveSession removes the file SessionPath, if set and present.
+func (ui *UI) RemoveSession() error {
+	if ui.SessionPath == "" {
+		return nil
+	}
+	if err := os.Remove(ui.SessionPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Resume restores the state of ui saved in the session file path. Misspellings
+// whose source files changed since they were loaded are marked as stale.
+func (ui *UI) Resume(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s Session
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if s.Version < 1 || s.Version > SessionVersion {
+		return fmt.Errorf("%s: unsupported session version %d, want version %d or earlier", path, s.Version, SessionVersion)
+	}
+
+	changed := make(map[string]bool)
+	for filename, hash := range s.Hashes {
+		changed[filename] = fileHash(filename) != hash
+	}
+	ui.Misspellings = nil
+	ui.Stale = make(map[*types.Misspelling]bool)
+	for _, pkg := range s.Packages {
+		for _, text := range pkg.Documentation {
+			text.Package = pkg
+			for _, m := range text.Misspellings {
+				m.Text = text
+				ui.Misspellings = append(ui.Misspellings, m)
+				if changed[text.Position.Filename] {
+					ui.Stale[m] = true
+				}
+			}
+		}
+	}
+	ui.Hashes = s.Hashes
+	ui.Index = 0
+	if s.Index >= 0 && s.Index < len(ui.Misspellings) {
+		ui.Index = s.Index
+	}
+	ui.DoneLoadingInput = true
+	return nil
+}
@@ -0,0 +1,157 @@
+package fix
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/apply"
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestSessionResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	unchanged, changed := filepath.Join(dir, "unchanged.txt"), filepath.Join(dir, "changed.txt")
+	for _, filename := range []string{unchanged, changed} {
+		if err := ioutil.WriteFile(filename, []byte("teh end\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg := &types.Package{Name: dir}
+	for _, filename := range []string{unchanged, changed} {
+		pkg.Documentation = append(pkg.Documentation, &types.Text{
+			Content:      "teh end",
+			Position:     token.Position{Filename: filename, Offset: 0, Line: 1, Column: 1},
+			Misspellings: []*types.Misspelling{{Word: "teh", Suggestions: []string{"the"}}},
+			Package:      pkg,
+		})
+	}
+	ui := NewUI()
+	ui.SessionPath = filepath.Join(dir, "session.json")
+	for _, text := range pkg.Documentation {
+		m := text.Misspellings[0]
+		m.Text = text
+		ui.hashFile(text.Position.Filename)
+		ui.Misspellings = append(ui.Misspellings, m)
+	}
+	ui.Misspellings[0].Action = types.Action{Type: types.Replace, Replacement: "the"}
+	ui.Index = 1
+	if err := ui.SaveSession(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(changed, []byte("the end\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := NewUI()
+	if err := resumed.Resume(ui.SessionPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Misspellings) != 2 || resumed.Index != 1 || !resumed.DoneLoadingInput {
+		t.Fatalf("resumed %d misspellings at index %d, want 2 at index 1", len(resumed.Misspellings), resumed.Index)
+	}
+	for i, m := range resumed.Misspellings {
+		if m.Action != ui.Misspellings[i].Action {
+			t.Errorf("misspelling %d has action %+v, want %+v", i, m.Action, ui.Misspellings[i].Action)
+		}
+		if m.Text == nil || m.Text.Package == nil || m.Text.Position.Filename != ui.Misspellings[i].Text.Position.Filename {
+			t.Errorf("misspelling %d is not linked to its text and package", i)
+		}
+		if stale := m.Text.Position.Filename == changed; resumed.Stale[m] != stale {
+			t.Errorf("misspelling in %s is stale: %v, want %v", m.Text.Position.Filename, resumed.Stale[m], stale)
+		}
+	}
+
+	// the hashes of the original files are kept
+	resumed.SessionPath = ui.SessionPath
+	if err := resumed.SaveSession(); err != nil {
+		t.Fatal(err)
+	}
+	again := NewUI()
+	if err := again.Resume(ui.SessionPath); err != nil {
+		t.Fatal(err)
+	}
+	if again.Hashes[changed] != ui.Hashes[changed] || len(again.Stale) != 1 {
+		t.Errorf("hash of changed file = %s, want %s, and got %d stale misspellings, want 1", again.Hashes[changed], ui.Hashes[changed], len(again.Stale))
+	}
+
+	if err := ioutil.WriteFile(ui.SessionPath, []byte(`{"Version":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewUI().Resume(ui.SessionPath); err == nil {
+		t.Error("resumed a session of an unsupported version")
+	}
+}
+
+func TestSessionApplied(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "text.txt")
+	if err := ioutil.WriteFile(filename, []byte("burows and okk\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg := &types.Package{Name: dir}
+	text := &types.Text{
+		Content:  "burows and okk",
+		Position: token.Position{Filename: filename, Offset: 0, Line: 1, Column: 1},
+		Package:  pkg,
+	}
+	fixed := &types.Misspelling{
+		Word:        "burows",
+		Suggestions: []string{"burrows"},
+		Action:      types.Action{Type: types.Replace, Replacement: "burrows"},
+		Text:        text,
+	}
+	left := &types.Misspelling{Word: "okk", Offset: 11, Suggestions: []string{"ok"}, Text: text}
+	text.Misspellings = []*types.Misspelling{fixed, left}
+	pkg.Documentation = []*types.Text{text}
+
+	ui := NewUI()
+	ui.SessionPath = filepath.Join(dir, "session.json")
+	ui.hashFile(filename)
+	ui.Misspellings = []*types.Misspelling{fixed, left}
+	ui.Index = 1
+	ui.forget(apply.Apply(ui.Misspellings))
+	if len(ui.Misspellings) != 1 || ui.Misspellings[0] != left || ui.Index != 0 {
+		t.Fatalf("got %d misspellings at index %d after applying one of 2, want the other at index 0", len(ui.Misspellings), ui.Index)
+	}
+	if len(text.Misspellings) != 1 || text.Misspellings[0] != left {
+		t.Errorf("text has %d misspellings, want the one not applied", len(text.Misspellings))
+	}
+	if !ui.Stale[left] {
+		t.Error("misspelling shifted by a longer replacement is not stale")
+	}
+	if err := ui.SaveSession(); err != nil {
+		t.Fatal(err)
+	}
+	resumed := NewUI()
+	if err := resumed.Resume(ui.SessionPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Misspellings) != 1 || resumed.Misspellings[0].Word != "okk" {
+		t.Fatalf("resumed %d misspellings, want okk only", len(resumed.Misspellings))
+	}
+	if !resumed.Stale[resumed.Misspellings[0]] {
+		t.Error("misspelling in a file written by apply is not stale when resumed")
+	}
+
+	if err := ui.RemoveSession(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(ui.SessionPath); !os.IsNotExist(err) {
+		t.Errorf("session file not removed: %v", err)
+	}
+	if err := ui.RemoveSession(); err != nil {
+		t.Errorf("removing a missing session: %v", err)
+	}
+}
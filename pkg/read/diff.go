@@ -0,0 +1,205 @@
+package read
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// DiffFormat can read documentation from the lines added in a unified diff,
+// like the output of git diff or diff -u.
+type DiffFormat struct {
+	// Dir is the directory where the files changed by the diff are, usually
+	// the root of a repository. It defaults to the current directory.
+	Dir string
+}
+
+// ReadDir extracts the lines added by the unified diff in path, or in STDIN if
+// path is "-". It returns one package per changed file, named after the file
+// in the post-image.
+//
+// Positions refer to the post-image files, which are read from Dir to compute
+// byte offsets. When a file cannot be read or does not match the diff, the
+// offsets of its lines are -1, and they cannot be fixed automatically.
+func (f DiffFormat) ReadDir(path string) ([]*types.Package, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	return f.ReadDiff(r)
+}
+
+// ReadDiff extracts the lines added by the unified diff read from r.
+func (f DiffFormat) ReadDiff(r io.Reader) ([]*types.Package, error) {
+	var (
+		pkgs     []*types.Package
+		pkg      *types.Package
+		postFile *postImage
+		// lines remaining in the current hunk
+		oldLines, newLines int
+		newLine            int
+		// the pre-image of the current file, and whether a git header
+		// showed a/ and b/ prefixes
+		oldName     string
+		gitPrefixed bool
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if oldLines > 0 || newLines > 0 {
+			if line == "" {
+				// some tools strip the space of empty context lines
+				line = " "
+			}
+			switch line[0] {
+			case ' ':
+				oldLines--
+				newLines--
+				newLine++
+			case '-':
+				oldLines--
+			case '+':
+				newLines--
+				if pkg != nil {
+					pkg.Documentation = append(pkg.Documentation, &types.Text{
+						Content:  line[1:],
+						Position: postFile.position(newLine, line[1:]),
+					})
+				}
+				newLine++
+			case '\\':
+				// "\ No newline at end of file"
+			default:
+				return pkgs, fmt.Errorf("line %d: unexpected line in hunk: %q", lineno, line)
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			names := line[len("diff --git "):]
+			gitPrefixed = (strings.HasPrefix(names, "a/") || strings.HasPrefix(names, `"a/`)) &&
+				(strings.Contains(names, " b/") || strings.Contains(names, ` "b/`))
+		case strings.HasPrefix(line, "--- "):
+			oldName = diffFileName(line[len("--- "):])
+		case strings.HasPrefix(line, "+++ "):
+			name := diffFileName(line[len("+++ "):])
+			if gitPrefixed || diffPrefixed(oldName, name) {
+				name = name[strings.IndexByte(name, '/')+1:]
+			}
+			gitPrefixed = false
+			if name == "" {
+				// the file was deleted
+				pkg = nil
+				continue
+			}
+			dir := f.Dir
+			if dir == "" {
+				dir = "."
+			}
+			filename, err := filepath.Abs(filepath.Join(dir, name))
+			if err != nil {
+				return pkgs, err
+			}
+			pkg = &types.Package{Name: name}
+			pkgs = append(pkgs, pkg)
+			postFile = &postImage{filename: filename}
+		case strings.HasPrefix(line, "@@ "):
+			m := diffHunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return pkgs, fmt.Errorf("line %d: invalid hunk header: %q", lineno, line)
+			}
+			oldLines, newLines = 1, 1
+			if m[2] != "" {
+				oldLines, _ = strconv.Atoi(m[2])
+			}
+			newLine, _ = strconv.Atoi(m[3])
+			if m[4] != "" {
+				newLines, _ = strconv.Atoi(m[4])
+			}
+		}
+	}
+	return pkgs, scanner.Err()
+}
+
+var diffHunkHeader = regexp.MustCompile(`^@@ -([0-9]+)(?:,([0-9]+))? \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// diffFileName returns the path of a file in a ---/+++ header line, without
+// timestamp. It returns "" for /dev/null.
+func diffFileName(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// diffPrefixed tells whether the paths old and new of a ---/+++ header pair
+// start with different directories before the same path, like a/ and b/ in
+// diffs of git or of two copies of a tree. As patch -p1 does, those
+// directories are stripped.
+func diffPrefixed(old, new string) bool {
+	i, j := strings.IndexByte(old, '/'), strings.IndexByte(new, '/')
+	return i > 0 && j > 0 && old[:i] != new[:j] && old[i+1:] == new[j+1:]
+}
+
+// postImage is a file as changed by a diff, read on demand to find the
+// offsets of its lines.
+type postImage struct {
+	filename   string
+	read       bool
+	content    []byte
+	lineStarts []int
+}
+
+// position returns the position of line number n, with the given content, in
+// the post-image. The offset is -1 if the file does not have that content.
+func (p *postImage) position(n int, content string) token.Position {
+	pos := token.Position{Filename: p.filename, Offset: -1, Line: n, Column: 1}
+	if !p.read {
+		p.read = true
+		b, err := ioutil.ReadFile(p.filename)
+		if err == nil {
+			p.content = b
+			p.lineStarts = []int{0}
+			for i, c := range b {
+				if c == '\n' {
+					p.lineStarts = append(p.lineStarts, i+1)
+				}
+			}
+		}
+	}
+	if n < 1 || n > len(p.lineStarts) {
+		return pos
+	}
+	begin := p.lineStarts[n-1]
+	if bytes.HasPrefix(p.content[begin:], []byte(content)) {
+		rest := p.content[begin+len(content):]
+		if len(rest) == 0 || rest[0] == '\n' || rest[0] == '\r' {
+			pos.Offset = begin
+		}
+	}
+	return pos
+}
@@ -0,0 +1,96 @@
+package read
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDirDiff(t *testing.T) {
+	dir := "testdata/diff"
+	path := filepath.Join(dir, "changes.patch")
+	pkgs, err := DiffFormat{Dir: dir}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type text struct {
+		content              string
+		offset, line, column int
+	}
+	want := []struct {
+		name  string
+		texts []text
+	}{
+		{"docs/gopher.txt", []text{
+			{"They live in burows.", 26, 2, 1},
+			{"They eat roots.", 47, 3, 1},
+		}},
+		{"crlf.txt", []text{
+			{"First line", 0, 1, 1},
+			{"Secnd line", 12, 2, 1},
+		}},
+		{"missing.txt", []text{
+			{"added elsewhere", -1, 11, 1},
+		}},
+	}
+	if got, want := len(pkgs), len(want); got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	for i, want := range want {
+		pkg := pkgs[i]
+		if pkg.Name != want.name {
+			t.Errorf("pkgs[%d].Name = %q, want %q", i, pkg.Name, want.name)
+		}
+		if got, want := len(pkg.Documentation), len(want.texts); got != want {
+			t.Errorf("len(%s.Documentation) = %d, want %d", pkg.Name, got, want)
+			continue
+		}
+		filename, err := filepath.Abs(filepath.Join(dir, want.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, want := range want.texts {
+			got := pkg.Documentation[j]
+			if got.Content != want.content {
+				t.Errorf("%s.Documentation[%d].Content = %q, want %q", pkg.Name, j, got.Content, want.content)
+			}
+			if got.Position.Filename != filename {
+				t.Errorf("%s.Documentation[%d].Filename = %q, want %q", pkg.Name, j, got.Position.Filename, filename)
+			}
+			if got.Position.Offset != want.offset {
+				t.Errorf("%s.Documentation[%d].Offset = %d, want %d", pkg.Name, j, got.Position.Offset, want.offset)
+			}
+			if got.Position.Line != want.line {
+				t.Errorf("%s.Documentation[%d].Line = %d, want %d", pkg.Name, j, got.Position.Line, want.line)
+			}
+			if got.Position.Column != want.column {
+				t.Errorf("%s.Documentation[%d].Column = %d, want %d", pkg.Name, j, got.Position.Column, want.column)
+			}
+		}
+	}
+}
+
+func TestReadDiffPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		diff, name string
+	}{
+		// a real b/ directory is kept
+		{"--- b/notes.txt.orig\n+++ b/notes.txt\n", "b/notes.txt"},
+		{"diff --git b/notes.txt b/notes.txt\n--- b/notes.txt\n+++ b/notes.txt\n", "b/notes.txt"},
+		// diffs of two trees, and new and renamed files in git
+		{"--- old/docs/notes.txt\n+++ new/docs/notes.txt\n", "docs/notes.txt"},
+		{"diff --git a/notes.txt b/notes.txt\nnew file mode 100644\n--- /dev/null\n+++ b/notes.txt\n", "notes.txt"},
+		{"diff --git a/old.txt b/notes.txt\n--- a/old.txt\n+++ b/notes.txt\n", "notes.txt"},
+		// without prefixes, as with git diff --no-prefix
+		{"diff --git docs/notes.txt docs/notes.txt\n--- docs/notes.txt\n+++ docs/notes.txt\n", "docs/notes.txt"},
+		{"--- /dev/null\n+++ b/notes.txt\n", "b/notes.txt"},
+	} {
+		pkgs, err := DiffFormat{}.ReadDiff(strings.NewReader(tt.diff + "@@ -0,0 +1 @@\n+text\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkgs) != 1 || pkgs[0].Name != tt.name {
+			t.Errorf("ReadDiff(%q) = %+v, want a package named %q", tt.diff, pkgs, tt.name)
+		}
+	}
+}
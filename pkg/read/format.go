@@ -0,0 +1,268 @@
+package read
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/stream"
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// FileReader is implemented by readers that can read a single file.
+type FileReader interface {
+	ReadFile(path string, fi os.FileInfo) (*types.Package, error)
+}
+
+// formatNames are the names of the known formats, in the order they are read
+// from a directory.
+var formatNames = []string{"go", "adoc", "md", "txt", "py", "diff", "wiki"}
+
+// formats maps format names to readers.
+var formats = map[string]DirReader{
+	"go":   GoFormat{},
+	"adoc": AsciiDocFormat{},
+	"md":   MarkdownFormat{},
+	"txt":  TextFormat{},
+	"py":   PythonFormat{},
+	"diff": DiffFormat{},
+	"wiki": WikipediaFormat{},
+}
+
+// formatExtensions maps file extensions to format names.
+var formatExtensions = map[string]string{
+	".go":       "go",
+	".adoc":     "adoc",
+	".md":       "md",
+	".markdown": "md",
+	".mdown":    "md",
+	".mkd":      "md",
+	".txt":      "txt",
+	".py":       "py",
+	".diff":     "diff",
+	".patch":    "diff",
+}
+
+// Register makes a format available by name. Files are detected to be in the
+// format only if its extensions are registered with RegisterExtension.
+// Register is meant to be called from init functions, and it panics if the
+// name is already registered or dirReader is nil.
+func Register(name string, dirReader DirReader) {
+	if dirReader == nil {
+		panic("read: Register reader is nil")
+	}
+	if _, dup := formats[name]; dup {
+		panic("read: Register called twice for format " + name)
+	}
+	formats[name] = dirReader
+	formatNames = append(formatNames, name)
+}
+
+// RegisterExtension makes files with the extension ext, including the dot,
+// be read in the registered format with the given name. Files found in
+// directories are only read if the reader of the format implements
+// FileReader; the others read only files given by path, like diffs.
+func RegisterExtension(ext, name string) {
+	formatExtensions[ext] = name
+}
+
+// FormatNames returns the names of the registered formats.
+func FormatNames() []string {
+	return append([]string(nil), formatNames...)
+}
+
+// LookupFormat returns the reader of the format with the given name. Formats
+// that are not registered are read by external readers, executables named
+// typokiller-read-NAME found in the PATH environment variable.
+func LookupFormat(name string) (DirReader, error) {
+	if r, ok := formats[name]; ok {
+		return r, nil
+	}
+	if name != "" && !strings.ContainsAny(name, `/\`) {
+		if path, err := exec.LookPath(externalReaderPrefix + name); err == nil {
+			return ExternalFormat{Path: path}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown format %q, use one of %s, or install %s%s in PATH",
+		name, strings.Join(formatNames, ", "), externalReaderPrefix, name)
+}
+
+// externalReaderPrefix is the prefix of the names of external readers.
+const externalReaderPrefix = "typokiller-read-"
+
+// ExternalFormat can read documentation with an external reader, an executable
+// that takes a path as its only argument, and prints to its standard output a
+// stream of packages in JSON, one per line, like typokiller read. The reader
+// inherits the standard input and error, so it may read "-" from STDIN.
+type ExternalFormat struct {
+	// Path is the path of the executable.
+	Path string
+}
+
+// ReadDir extracts documentation metadata from path with the external reader.
+func (f ExternalFormat) ReadDir(path string) ([]*types.Package, error) {
+	var r []*types.Package
+	err := f.ReadStream(path, func(p *types.Package) error {
+		r = append(r, p)
+		return nil
+	})
+	return r, err
+}
+
+// ReadStream runs the external reader on path, calling fn for each package it
+// prints, as soon as it is printed.
+func (f ExternalFormat) ReadStream(path string, fn func(*types.Package) error) error {
+	cmd := exec.Command(f.Path, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	dec := stream.NewDecoder(stdout)
+	for {
+		p, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			err = fmt.Errorf("%s %s: %v", filepath.Base(f.Path), path, err)
+			return f.stop(cmd, err)
+		}
+		if err := fn(p); err != nil {
+			return f.stop(cmd, err)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s %s: %v", filepath.Base(f.Path), path, err)
+	}
+	return nil
+}
+
+// stop kills the external reader, which may be blocked writing to its output,
+// and returns err.
+func (f ExternalFormat) stop(cmd *exec.Cmd, err error) error {
+	cmd.Process.Kill()
+	cmd.Wait()
+	return err
+}
+
+// FormatOf returns the name of the format of a file, detected from its
+// extension or from its conventional name, like README. It returns false if
+// the format is unknown.
+func FormatOf(filename string) (string, bool) {
+	base := filepath.Base(filename)
+	ext := filepath.Ext(base)
+	if name, ok := formatExtensions[ext]; ok {
+		return name, true
+	}
+	if ext == "" && textFileNames[strings.ToUpper(base)] {
+		return "txt", true
+	}
+	return "", false
+}
+
+// AutoFormat can read documentation in any known format, detected per file
+// with FormatOf.
+type AutoFormat struct {
+	// Formats overrides the readers of some formats, by name, for instance
+	// to read Go code with a configured GoFormat.
+	Formats map[string]DirReader
+}
+
+// reader returns the reader of the format with the given name.
+func (f AutoFormat) reader(name string) DirReader {
+	if r, ok := f.Formats[name]; ok {
+		return r
+	}
+	return formats[name]
+}
+
+// ReadDir extracts documentation metadata from the files in path, sending each
+// file to the reader of its format. Files of unknown formats are skipped, and
+// so are files of formats whose readers do not implement FileReader, like
+// diffs, which are not documentation of the directory but changes to other
+// files. If path is a file, it is read on its own, and its format must be
+// known. Patterns of Go
+// packages, like ./..., are read as Go code.
+// It does not recurse into subdirectories.
+func (f AutoFormat) ReadDir(path string) ([]*types.Package, error) {
+	if isGoPattern(path) {
+		return f.reader("go").ReadDir(path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return f.readFile(path, fi)
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, entry := range entries {
+		if name, ok := FormatOf(entry.Name()); ok && entry.Mode().IsRegular() {
+			found[name] = true
+		}
+	}
+	var r []*types.Package
+	for _, name := range formatNames {
+		reader := f.reader(name)
+		if _, ok := reader.(FileReader); !ok || !found[name] {
+			continue
+		}
+		pkgs, err := reader.ReadDir(path)
+		r = append(r, pkgs...)
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// ReadTree returns an AutoFormat that reads Go code in path and its
+// subdirectories at once, if its reader of Go code is a TreeReader.
+func (f AutoFormat) ReadTree(path string) (DirReader, error) {
+	tr, ok := f.reader("go").(TreeReader)
+	if !ok {
+		return f, nil
+	}
+	goReader, err := tr.ReadTree(path)
+	if err != nil {
+		return nil, err
+	}
+	formats := map[string]DirReader{"go": goReader}
+	for name, r := range f.Formats {
+		if name != "go" {
+			formats[name] = r
+		}
+	}
+	return AutoFormat{Formats: formats}, nil
+}
+
+// readFile reads a single file with the reader of its format.
+func (f AutoFormat) readFile(path string, fi os.FileInfo) ([]*types.Package, error) {
+	name, ok := FormatOf(path)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown format", path)
+	}
+	reader := f.reader(name)
+	fr, ok := reader.(FileReader)
+	if !ok {
+		// readers of single files, like diffs
+		return reader.ReadDir(path)
+	}
+	p, err := fr.ReadFile(path, fi)
+	if err != nil {
+		return nil, err
+	}
+	return []*types.Package{p}, nil
+}
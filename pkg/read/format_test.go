@@ -0,0 +1,178 @@
+package read
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestFormatOf(t *testing.T) {
+	for _, tt := range []struct {
+		filename string
+		want     string
+		ok       bool
+	}{
+		{"hello.go", "go", true},
+		{"docs/index.adoc", "adoc", true},
+		{"README.md", "md", true},
+		{"CHANGES.markdown", "md", true},
+		{"notes.txt", "txt", true},
+		{"LICENSE", "txt", true},
+		{"readme", "txt", true},
+		{"setup.py", "py", true},
+		{"fix.patch", "diff", true},
+		{"data.json", "", false},
+		{"Makefile", "", false},
+	} {
+		got, ok := FormatOf(tt.filename)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FormatOf(%q) = %q, %v, want %q, %v", tt.filename, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLookupFormat(t *testing.T) {
+	for _, name := range FormatNames() {
+		if _, err := LookupFormat(name); err != nil {
+			t.Errorf("LookupFormat(%q) returned err=%v, want nil", name, err)
+		}
+	}
+	if _, err := LookupFormat("golang"); err == nil {
+		t.Errorf("LookupFormat(%q) returned err=nil, want error", "golang")
+	}
+}
+
+func TestReadDirAuto(t *testing.T) {
+	path := "testdata/mixed"
+	pkgs, err := AutoFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range pkgs {
+		for _, text := range pkg.Documentation {
+			got = append(got, filepath.Base(text.Position.Filename)+": "+text.Content)
+		}
+	}
+	want := []string{
+		"gopher.go: Package gopher is a Go package.",
+		"gophers.adoc: Gophers in AsciiDoc.\n",
+		"gophers.md: Gophers",
+		"gophers.md: Gophers in Markdown.",
+		"README: Gophers in plain text.",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadDir(%q) read %q, want %q", path, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ReadDir(%q) text %d = %q, want %q", path, i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadDirAutoFile(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		pkgs int
+	}{
+		{"testdata/mixed/gopher.go", 1},
+		{"testdata/mixed/README", 1},
+		{"testdata/diff/changes.patch", 3},
+	} {
+		pkgs, err := AutoFormat{}.ReadDir(tt.path)
+		if err != nil {
+			t.Errorf("ReadDir(%q) returned err=%v", tt.path, err)
+			continue
+		}
+		if len(pkgs) != tt.pkgs {
+			t.Errorf("ReadDir(%q) got %d packages, want %d", tt.path, len(pkgs), tt.pkgs)
+		}
+	}
+	path := "testdata/mixed/gophers.json"
+	if _, err := (AutoFormat{}).ReadDir(path); err == nil {
+		t.Errorf("ReadDir(%q) returned err=nil, want error", path)
+	}
+}
+
+type fakeFormat struct{}
+
+func (fakeFormat) ReadDir(path string) ([]*types.Package, error) {
+	return []*types.Package{{Name: path}}, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake", fakeFormat{})
+	RegisterExtension(".fake", "fake")
+	defer func() {
+		delete(formats, "fake")
+		delete(formatExtensions, ".fake")
+		formatNames = formatNames[:len(formatNames)-1]
+	}()
+	if r, err := LookupFormat("fake"); err != nil || r != (fakeFormat{}) {
+		t.Errorf("LookupFormat(%q) = %#v, %v, want %#v, nil", "fake", r, err, fakeFormat{})
+	}
+	if name, ok := FormatOf("x.fake"); name != "fake" || !ok {
+		t.Errorf("FormatOf(%q) = %q, %v, want %q, true", "x.fake", name, ok, "fake")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register(%q) twice did not panic", "fake")
+		}
+	}()
+	Register("fake", fakeFormat{})
+}
+
+func TestExternalFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scripts := map[string]string{
+		"ok":     `echo '{"PackageName":"one","Documentation":[{"Content":"'"$1"'"}]}'; echo '{"PackageName":"two"}'`,
+		"fail":   `exit 3`,
+		"broken": `echo '{"PackageName":'`,
+	}
+	for name, script := range scripts {
+		path := filepath.Join(dir, externalReaderPrefix+name)
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+
+	r, err := LookupFormat("ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := r.ReadDir("some/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Name != "one" || pkgs[1].Name != "two" {
+		t.Fatalf("ReadDir read %#v, want packages one and two", pkgs)
+	}
+	if got, want := pkgs[0].Documentation[0].Content, "some/path"; got != want {
+		t.Errorf("pkgs[0].Documentation[0].Content = %q, want %q", got, want)
+	}
+	for _, name := range []string{"fail", "broken"} {
+		r, err := LookupFormat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadDir("some/path"); err == nil {
+			t.Errorf("%s ReadDir returned err=nil, want error", name)
+		}
+	}
+	if _, err := LookupFormat("missing"); err == nil {
+		t.Errorf("LookupFormat(%q) returned err=nil, want error", "missing")
+	}
+	if _, err := LookupFormat("../ok"); err == nil {
+		t.Errorf("LookupFormat(%q) returned err=nil, want error", "../ok")
+	}
+}
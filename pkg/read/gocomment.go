@@ -0,0 +1,209 @@
+package read
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc/comment"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// goCommentLine is a line of text of a comment, without comment markers.
+type goCommentLine struct {
+	offset int // offset of text in the source file
+	text   string
+	prose  bool
+}
+
+// goCommentTexts returns the prose of the comment group cg in the source file
+// src as texts, one per run of consecutive prose lines, like paragraphs and
+// list items. Comments are parsed with go/doc/comment: directives, code blocks
+// and link definitions are skipped, and within prose, URLs and doc links are
+// replaced with spaces, so that offsets still match the file.
+func goCommentTexts(cg *ast.CommentGroup, file *token.File, src []byte) []*types.Text {
+	lines := goCommentLines(cg, file)
+	var text []string
+	for _, l := range lines {
+		text = append(text, l.text)
+	}
+	p := comment.Parser{
+		// all names in brackets link to symbols of the package
+		LookupSym: func(recv, name string) bool { return true },
+	}
+	doc := p.Parse(strings.Join(text, "\n"))
+
+	// Code blocks and link definitions are not prose. Their lines are
+	// found in order, since the parser does not keep their positions.
+	i := 0
+	for _, block := range doc.Content {
+		code, ok := block.(*comment.Code)
+		if !ok {
+			continue
+		}
+		for _, s := range strings.Split(code.Text, "\n") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			for ; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i].text) == s && strings.IndexAny(lines[i].text, " \t") == 0 {
+					lines[i].prose = false
+					i++
+					break
+				}
+			}
+		}
+	}
+	for _, def := range doc.Links {
+		for i := range lines {
+			if strings.HasPrefix(strings.TrimSpace(lines[i].text), "["+def.Text+"]:") {
+				lines[i].prose = false
+			}
+		}
+	}
+
+	var masks []string
+	goCommentLinks(doc.Content, func(s string) { masks = append(masks, s) })
+
+	var r []*types.Text
+	for i := 0; i < len(lines); {
+		if !lines[i].prose {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(lines) && lines[j].prose {
+			j++
+		}
+		begin := lines[i].offset
+		end := lines[j-1].offset + len(lines[j-1].text)
+		content := append([]byte(nil), src[begin:end]...)
+		for k := i; k < j; k++ {
+			l := lines[k]
+			if k > i {
+				// comment markers between lines
+				prev := lines[k-1]
+				maskBytes(content, prev.offset+len(prev.text)-begin, l.offset-begin)
+			}
+			line := content[l.offset-begin : l.offset-begin+len(l.text)]
+			if m := goCommentHeading.FindIndex(line); m != nil {
+				maskBytes(line, m[0], m[1])
+			}
+			for _, s := range masks {
+				for n := 0; ; {
+					m := bytes.Index(line[n:], []byte(s))
+					if m < 0 {
+						break
+					}
+					maskBytes(line, n+m, n+m+len(s))
+					n += m + len(s)
+				}
+			}
+		}
+		r = append(r, &types.Text{
+			Content:  string(content),
+			Position: file.Position(file.Pos(begin)),
+		})
+		i = j
+	}
+	return r
+}
+
+// goCommentHeading matches the marker of a heading line.
+var goCommentHeading = regexp.MustCompile(`^#\s`)
+
+// goCommentLines returns the lines of text of the comment group cg, as
+// go/ast.CommentGroup.Text would, except that directive lines are kept, but
+// not as prose.
+func goCommentLines(cg *ast.CommentGroup, file *token.File) []goCommentLine {
+	var lines []goCommentLine
+	for _, c := range cg.List {
+		offset := file.Offset(c.Pos()) + 2
+		if c.Text[1] == '/' {
+			text := c.Text[2:]
+			if isGoDirective(text) {
+				lines = append(lines, goCommentLine{offset: offset, text: text})
+				continue
+			}
+			if strings.HasPrefix(text, " ") {
+				offset++
+				text = text[1:]
+			}
+			lines = append(lines, goCommentLine{offset: offset, text: text, prose: true})
+			continue
+		}
+		// /*-style comment
+		for _, text := range strings.Split(c.Text[2:len(c.Text)-2], "\n") {
+			lines = append(lines, goCommentLine{offset: offset, text: text, prose: true})
+			offset += len(text) + 1
+		}
+	}
+	for i := range lines {
+		if strings.TrimSpace(lines[i].text) == "" {
+			lines[i].prose = false
+		}
+	}
+	return lines
+}
+
+// goDirective matches directives like //go:generate and //lint:ignore.
+var goDirective = regexp.MustCompile(`^[a-z0-9]+:[a-z0-9]`)
+
+// isGoDirective returns whether the text of a //-style comment, after the
+// slashes, is a directive for tools rather than documentation, as defined by
+// go/ast, or a //nolint comment.
+func isGoDirective(text string) bool {
+	for _, prefix := range []string{"line ", "extern ", "export ", "nolint"} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return goDirective.MatchString(text)
+}
+
+// goCommentLinks calls fn with the text of the auto-detected URLs and of the
+// doc links, including their brackets, in blocks.
+func goCommentLinks(blocks []comment.Block, fn func(string)) {
+	inline := func(texts []comment.Text) {
+		for _, t := range texts {
+			switch t := t.(type) {
+			case *comment.Link:
+				if t.Auto {
+					fn(t.URL)
+				}
+			case *comment.DocLink:
+				var b strings.Builder
+				for _, t := range t.Text {
+					if p, ok := t.(comment.Plain); ok {
+						b.WriteString(string(p))
+					}
+				}
+				fn("[" + b.String() + "]")
+			}
+		}
+	}
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *comment.Paragraph:
+			inline(block.Text)
+		case *comment.Heading:
+			inline(block.Text)
+		case *comment.List:
+			for _, item := range block.Items {
+				goCommentLinks(item.Content, fn)
+			}
+		}
+	}
+}
+
+// maskBytes replaces the bytes of b in [begin, end) with spaces, except
+// newlines.
+func maskBytes(b []byte, begin, end int) {
+	for i := begin; i < end; i++ {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+}
@@ -2,27 +2,377 @@ package read
 
 import (
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	gotypes "go/types"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
 
 	"github.com/rhcarvalho/typokiller/pkg/types"
 )
 
-// GoFormat can read documentation from Go source code.
-type GoFormat struct{}
+// GoFormat can read documentation from Go source code. Packages are loaded as
+// the go command does, honoring modules, build constraints and the GOFLAGS
+// environment variable. Outside of modules, where the go command cannot load
+// packages, source files are parsed on their own, without type information.
+type GoFormat struct {
+	// Strings makes the reader also extract string literals, like error
+	// messages and usage help, except import paths and struct tags.
+	Strings bool
+	// Identifiers makes the reader also extract the declared identifiers, to
+	// spellcheck the words they are made of.
+	Identifiers bool
+	// Tags are the build tags that select the files to read, like the -tags
+	// flag of the go command.
+	Tags []string
+}
 
-// ReadDir extracts documentation metadata from Go files in path.
+// ReadDir extracts documentation metadata from the Go package in path, or
+// from the packages matching path if it is a pattern like ./...
 // This includes documentation comments and known identifiers.
 func (f GoFormat) ReadDir(path string) ([]*types.Package, error) {
-	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, path, nil, parser.ParseComments)
+	if isGoPattern(path) {
+		return f.Load("", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if !hasGoFiles(path) {
+		return nil, nil
+	}
+	return f.Load(path, ".")
+}
+
+// hasGoFiles returns whether the directory dir has Go files.
+func hasGoFiles(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	return len(matches) > 0
+}
+
+// isGoPattern returns whether path is a pattern matching many Go packages.
+func isGoPattern(path string) bool {
+	return strings.Contains(path, "...")
+}
+
+// Load extracts documentation metadata from the Go packages matching patterns,
+// resolved in dir, or in the current directory if dir is empty. Packages are
+// named by their import path. Test files are read with the package they test,
+// and external test packages are read as packages of their own, named with a
+// _test suffix.
+//
+// The names exported by imported packages, including the standard library,
+// are known identifiers too, so that comments can mention them.
+//
+// Outside of modules, patterns are directories, possibly ending in /..., and
+// packages are named by their package clause.
+func (f GoFormat) Load(dir string, patterns ...string) ([]*types.Package, error) {
+	if !inGoModule(dir) {
+		return f.parse(dir, patterns...)
+	}
+	loaded, err := packages.Load(f.config(dir), patterns...)
 	if err != nil {
 		return nil, err
 	}
 	var r []*types.Package
-	for _, pkg := range pkgs {
+	err = f.read(loaded, func(lp *packages.Package, p *types.Package) {
+		r = append(r, p)
+	})
+	return r, err
+}
+
+// read extracts documentation metadata from the loaded packages, calling fn
+// for each package read.
+func (f GoFormat) read(loaded []*packages.Package, fn func(*packages.Package, *types.Package)) error {
+	// Packages with tests are loaded twice, with and without their test
+	// files, and test binaries are loaded as main packages.
+	var paths []string
+	byPath := make(map[string]*packages.Package)
+	for _, lp := range loaded {
+		if strings.HasSuffix(lp.ID, ".test") {
+			continue
+		}
+		if len(lp.GoFiles) == 0 {
+			// no Go files, or build constraints exclude all of them;
+			// packages that do not build are still read, without the
+			// identifiers of the packages they fail to import
+			continue
+		}
+		prev, ok := byPath[lp.PkgPath]
+		if !ok {
+			paths = append(paths, lp.PkgPath)
+		}
+		if !ok || prev.ID == prev.PkgPath {
+			byPath[lp.PkgPath] = lp
+		}
+	}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		lp := byPath[path]
+		pkg := &ast.Package{Name: lp.PkgPath, Files: make(map[string]*ast.File)}
+		for _, filename := range lp.GoFiles {
+			file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+			if err != nil {
+				return err
+			}
+			pkg.Files[filename] = file
+		}
 		p, err := f.ReadPackage(pkg, fset)
+		if err != nil {
+			return err
+		}
+		p.Identifiers = withImportedIdents(p.Identifiers, lp)
+		fn(lp, p)
+	}
+	return nil
+}
+
+// ReadTree loads the Go packages in path and its subdirectories at once, and
+// returns a reader of their directories, so that walking them does not run the
+// go command for each directory. Directories the go command does not match
+// with path/..., like those of nested modules, are read on their own.
+func (f GoFormat) ReadTree(path string) (DirReader, error) {
+	if !inGoModule(path) {
+		return f, nil
+	}
+	loaded, err := packages.Load(f.config(path), "./...")
+	if err != nil {
+		return nil, err
+	}
+	t := goTree{GoFormat: f, pkgs: make(map[string][]*types.Package), loaded: make(map[string]bool)}
+	for _, lp := range loaded {
+		t.loaded[lp.Dir] = true
+	}
+	err = f.read(loaded, func(lp *packages.Package, p *types.Package) {
+		t.pkgs[lp.Dir] = append(t.pkgs[lp.Dir], p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// goTree reads the Go packages loaded by GoFormat.ReadTree, by directory.
+type goTree struct {
+	GoFormat
+	pkgs map[string][]*types.Package
+	// loaded are the directories of all packages loaded, including those
+	// without Go files to read.
+	loaded map[string]bool
+}
+
+// ReadDir returns the packages loaded in path, or reads path with the
+// GoFormat if it was not loaded.
+func (t goTree) ReadDir(path string) ([]*types.Package, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil || !t.loaded[abs] {
+		return t.GoFormat.ReadDir(path)
+	}
+	return t.pkgs[abs], nil
+}
+
+// config returns the configuration to load packages in dir with.
+func (f GoFormat) config(dir string) *packages.Config {
+	conf := &packages.Config{
+		// the types of dependencies are needed to know the names that
+		// imported packages export, not only those the package uses
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes,
+		Dir:   dir,
+		Tests: true,
+	}
+	if len(f.Tags) > 0 {
+		conf.BuildFlags = []string{"-tags=" + strings.Join(f.Tags, ",")}
+	}
+	return conf
+}
+
+// withImportedIdents returns idents with the names exported by the packages
+// that lp imports: the package names, their exported declarations and the
+// exported fields and methods of their types. The result is sorted, without
+// duplicates.
+func withImportedIdents(idents []string, lp *packages.Package) []string {
+	set := make(map[string]bool, len(idents))
+	for _, ident := range idents {
+		set[ident] = true
+	}
+	for _, imp := range lp.Imports {
+		pkg := imp.Types
+		if pkg == nil {
+			continue
+		}
+		set[pkg.Name()] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if !obj.Exported() {
+				continue
+			}
+			set[name] = true
+			if _, ok := obj.(*gotypes.TypeName); !ok {
+				continue
+			}
+			if named, ok := obj.Type().(*gotypes.Named); ok {
+				for i := 0; i < named.NumMethods(); i++ {
+					if m := named.Method(i); m.Exported() {
+						set[m.Name()] = true
+					}
+				}
+			}
+			switch t := obj.Type().Underlying().(type) {
+			case *gotypes.Struct:
+				for i := 0; i < t.NumFields(); i++ {
+					if field := t.Field(i); field.Exported() {
+						set[field.Name()] = true
+					}
+				}
+			case *gotypes.Interface:
+				for i := 0; i < t.NumMethods(); i++ {
+					if m := t.Method(i); m.Exported() {
+						set[m.Name()] = true
+					}
+				}
+			}
+		}
+	}
+	return sortedSet(set)
+}
+
+// ReadFile extracts documentation metadata from a single Go file, without
+// type information: the package is named by its package clause, and knows the
+// names of the packages imported by the file, but not the names they export.
+// Read the directory of the file to know them.
+func (f GoFormat) ReadFile(path string, fi os.FileInfo) (*types.Package, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &ast.Package{Name: file.Name.Name, Files: map[string]*ast.File{path: file}}
+	return f.ReadPackage(pkg, fset)
+}
+
+// inGoModule returns whether the go command can load packages in dir, or in
+// the current directory if dir is empty, because it is in a module or in
+// GOPATH mode. Directories with a go.mod file, in them or in their parents,
+// are in a module; for others, the go command is only asked once, as its
+// answer is the same for all of them.
+func inGoModule(dir string) bool {
+	if hasGoMod(dir) {
+		return true
+	}
+	goPathModeOnce.Do(func() {
+		cmd := exec.Command("go", "env", "GOMOD")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		// GOMOD is empty in GOPATH mode, and os.DevNull in module mode
+		// without a go.mod file
+		goPathMode = err == nil && strings.TrimSpace(string(out)) != os.DevNull
+	})
+	return goPathMode
+}
+
+var (
+	goPathModeOnce sync.Once
+	// goPathMode tells whether the go command loads packages outside of
+	// modules.
+	goPathMode bool
+)
+
+// hasGoMod returns whether dir, or any of its parents, has a go.mod file.
+// Like the go command, it ignores a go.mod file in the system temp directory.
+func hasGoMod(dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	tmp := filepath.Clean(os.TempDir())
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && fi.Mode().IsRegular() && dir != tmp {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// parse extracts documentation metadata from the Go files in the directories
+// matching patterns, resolved in dir, parsing them without the go command.
+// Patterns ending in /... match a directory and its subdirectories, except
+// those the go command ignores, like testdata. Files are selected by their
+// build constraints and f.Tags.
+func (f GoFormat) parse(dir string, patterns ...string) ([]*types.Package, error) {
+	ctxt := build.Default
+	ctxt.BuildTags = f.Tags
+	var r []*types.Package
+	for _, pattern := range patterns {
+		root := filepath.Join(dir, filepath.FromSlash(pattern))
+		if !isGoPattern(pattern) {
+			pkgs, err := f.parseDir(&ctxt, root)
+			r = append(r, pkgs...)
+			if err != nil {
+				return r, err
+			}
+			continue
+		}
+		root = filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(pattern, "...")))
+		if root == "" {
+			root = "."
+		}
+		err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				return nil
+			}
+			name := fi.Name()
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			pkgs, err := f.parseDir(&ctxt, path)
+			r = append(r, pkgs...)
+			return err
+		})
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// parseDir extracts documentation metadata from the Go files in dir that match
+// the build context ctxt. External test packages are read as packages of their
+// own.
+func (f GoFormat) parseDir(ctxt *build.Context, dir string) ([]*types.Package, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		ok, err := ctxt.MatchFile(dir, fi.Name())
+		return err == nil && ok
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var r []*types.Package
+	for _, name := range names {
+		p, err := f.ReadPackage(pkgs[name], fset)
 		if err != nil {
 			return r, err
 		}
@@ -31,29 +381,442 @@ func (f GoFormat) ReadDir(path string) ([]*types.Package, error) {
 	return r, nil
 }
 
-// ReadPackage extracts comments of a Go package.
-func (GoFormat) ReadPackage(pkg *ast.Package, fset *token.FileSet) (*types.Package, error) {
-	p := &types.Package{Name: pkg.Name}
-	for _, f := range pkg.Files {
+// ReadPackage extracts comments of a Go package, and string literals and
+// declared identifiers if f.Strings and f.Identifiers are set. The identifiers
+// used in the package are known once each, in order.
+func (f GoFormat) ReadPackage(pkg *ast.Package, fset *token.FileSet) (*types.Package, error) {
+	p := &types.Package{Name: pkg.Name, BuildTags: f.Tags}
+	idents := make(map[string]bool)
+	for _, file := range pkg.Files {
 		// Collect comments
-		for _, c := range f.Comments {
-			begin := fset.Position(c.Pos())
-			end := fset.Position(c.End())
-			b, err := ioutil.ReadFile(begin.Filename)
+		if len(file.Comments) > 0 {
+			tokFile := fset.File(file.Pos())
+			b, err := ioutil.ReadFile(tokFile.Name())
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range file.Comments {
+				p.Documentation = append(p.Documentation, goCommentTexts(c, tokFile, b)...)
+			}
+		}
+
+		// Collect declared identifiers
+		if f.Identifiers {
+			goDeclaredIdents(file, func(ident *ast.Ident, exported bool) {
+				p.Documentation = append(p.Documentation, &types.Text{
+					Content:    ident.Name,
+					Position:   fset.Position(ident.Pos()),
+					Identifier: true,
+					Exported:   exported,
+				})
+			})
+		}
+
+		// Collect string literals
+		if f.Strings {
+			texts, err := goStringTexts(file, fset)
 			if err != nil {
 				return nil, err
 			}
-			content := string(b[begin.Offset:end.Offset])
-			p.Documentation = append(p.Documentation, &types.Text{Content: content, Position: begin})
+			p.Documentation = append(p.Documentation, texts...)
 		}
 
 		// Collect identifiers
-		ast.Inspect(pkg, func(n ast.Node) bool {
+		ast.Inspect(file, func(n ast.Node) bool {
 			if ident, isIdent := n.(*ast.Ident); isIdent {
-				p.Identifiers = append(p.Identifiers, ident.String())
+				idents[ident.Name] = true
 			}
 			return true
 		})
 	}
+	p.Identifiers = sortedSet(idents)
 	return p, nil
 }
+
+// sortedSet returns the elements of set in order.
+func sortedSet(set map[string]bool) []string {
+	r := make([]string, 0, len(set))
+	for k := range set {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// goStringTexts returns the texts of the string literals in file, except
+// import paths and struct tags.
+func goStringTexts(file *ast.File, fset *token.FileSet) ([]*types.Text, error) {
+	skip := make(map[*ast.BasicLit]bool)
+	for _, imp := range file.Imports {
+		skip[imp.Path] = true
+	}
+	var lits []*ast.BasicLit
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			if n.Tag != nil {
+				skip[n.Tag] = true
+			}
+		case *ast.BasicLit:
+			if n.Kind == token.STRING && !skip[n] {
+				lits = append(lits, n)
+			}
+		}
+		return true
+	})
+	if len(lits) == 0 {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(fset.Position(file.Pos()).Filename)
+	if err != nil {
+		return nil, err
+	}
+	var r []*types.Text
+	for _, lit := range lits {
+		begin := fset.Position(lit.Pos())
+		end := fset.Position(lit.End())
+		r = append(r, goStringText(b[begin.Offset:end.Offset], begin))
+	}
+	return r, nil
+}
+
+// goStringText returns the text of the string literal lit found at pos. The
+// position of the text is that of the first byte after the opening quote.
+// Raw strings are copied verbatim, while interpreted strings are unquoted,
+// with SourceOffsets set to map their bytes back to the literal. Format verbs
+// are replaced with spaces.
+func goStringText(lit []byte, pos token.Position) *types.Text {
+	pos.Offset++
+	pos.Column++
+	if lit[0] == '`' {
+		content := maskFormatVerbs(lit[1 : len(lit)-1])
+		return &types.Text{Content: string(content), Position: pos}
+	}
+	content, offsets := goUnquote(lit[1 : len(lit)-1])
+	return &types.Text{Content: string(maskFormatVerbs(content)), Position: pos, SourceOffsets: offsets}
+}
+
+// goUnquote interprets the escape sequences of s, the content of an
+// interpreted string literal. It returns the unquoted content and the offset
+// in s of each of its bytes, plus len(s). Invalid escape sequences are kept
+// as they are.
+func goUnquote(s []byte) ([]byte, []int) {
+	var (
+		content []byte
+		offsets []int
+	)
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			content = append(content, s[i])
+			offsets = append(offsets, i)
+			i++
+			continue
+		}
+		value, multibyte, tail, err := strconv.UnquoteChar(string(s[i:]), '"')
+		if err != nil {
+			content = append(content, s[i])
+			offsets = append(offsets, i)
+			i++
+			continue
+		}
+		var buf [utf8.UTFMax]byte
+		n := 1
+		buf[0] = byte(value)
+		if multibyte {
+			n = utf8.EncodeRune(buf[:], value)
+		}
+		for _, c := range buf[:n] {
+			content = append(content, c)
+			offsets = append(offsets, i)
+		}
+		i = len(s) - len(tail)
+	}
+	return content, append(offsets, len(s))
+}
+
+// goFormatVerb matches the verbs of fmt format strings, like %v and %-10s.
+var goFormatVerb = regexp.MustCompile(`%[-+#0]*(?:\[[0-9]+\])?(?:[0-9]+|\*)?(?:\.(?:[0-9]+|\*)?)?(?:\[[0-9]+\])?[bcdeEfFgGopqstTUvxX%]`)
+
+// maskFormatVerbs returns a copy of b with format verbs replaced with spaces.
+func maskFormatVerbs(b []byte) []byte {
+	b = append([]byte(nil), b...)
+	for _, m := range goFormatVerb.FindAllIndex(b, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			b[i] = ' '
+		}
+	}
+	return b
+}
+
+// goDeclaredIdents calls add for every identifier declared in file, telling
+// whether it is exported: declared in the package block with an exported name
+// and, for fields and methods, of an exported type. Parameters, results and
+// identifiers declared in functions are never exported.
+func goDeclaredIdents(file *ast.File, add func(ident *ast.Ident, exported bool)) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			goDeclare(decl.Name, decl.Recv == nil || goExportedRecv(decl.Recv), add)
+			l := &goLocals{add: add}
+			l.push()
+			l.fields(decl.Recv)
+			l.fields(decl.Type.TypeParams)
+			l.fields(decl.Type.Params)
+			l.fields(decl.Type.Results)
+			if decl.Body != nil {
+				for _, stmt := range decl.Body.List {
+					l.walk(stmt)
+				}
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					goDeclare(spec.Name, true, add)
+					l := &goLocals{add: add}
+					l.push()
+					l.fields(spec.TypeParams)
+					goTypeIdents(spec.Type, spec.Name.IsExported(), add)
+				case *ast.ValueSpec:
+					exported := false
+					for _, name := range spec.Names {
+						goDeclare(name, true, add)
+						exported = exported || name.IsExported()
+					}
+					if spec.Type != nil {
+						goTypeIdents(spec.Type, exported, add)
+					}
+					l := &goLocals{add: add}
+					l.push()
+					for _, value := range spec.Values {
+						l.walk(value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// goDeclare calls add for ident, unless it is nil or blank, telling that it is
+// exported if exported is set and its name is exported.
+func goDeclare(ident *ast.Ident, exported bool, add func(ident *ast.Ident, exported bool)) {
+	if ident != nil && ident.Name != "_" {
+		add(ident, exported && ident.IsExported())
+	}
+}
+
+// goExportedRecv tells whether the type of the receiver recv is exported.
+func goExportedRecv(recv *ast.FieldList) bool {
+	if len(recv.List) == 0 {
+		return false
+	}
+	typ := recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.ParenExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.IsExported()
+		default:
+			return false
+		}
+	}
+}
+
+// goTypeIdents calls add for the fields, methods, parameters and results
+// declared in the type expression typ. Fields and methods with exported names
+// are exported if exported is set, and so are those of their types.
+func goTypeIdents(typ ast.Expr, exported bool, add func(ident *ast.Ident, exported bool)) {
+	ast.Inspect(typ, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.StructType:
+			goFieldIdents(n.Fields, exported, add)
+			return false
+		case *ast.InterfaceType:
+			goFieldIdents(n.Methods, exported, add)
+			return false
+		case *ast.FuncType:
+			goFieldIdents(n.TypeParams, false, add)
+			goFieldIdents(n.Params, false, add)
+			goFieldIdents(n.Results, false, add)
+			return false
+		}
+		return true
+	})
+}
+
+// goFieldIdents calls add for the names of fields, and for the identifiers
+// declared in their types, as goTypeIdents does.
+func goFieldIdents(fields *ast.FieldList, exported bool, add func(ident *ast.Ident, exported bool)) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		// embedded fields are named by their type
+		reachable := exported && len(field.Names) == 0
+		for _, name := range field.Names {
+			goDeclare(name, exported, add)
+			reachable = reachable || exported && name.IsExported()
+		}
+		goTypeIdents(field.Type, reachable, add)
+	}
+}
+
+// goLocals finds the identifiers declared in functions, which are never
+// exported. It keeps the names declared in each scope, so that variables
+// redeclared with := are only added where they are first declared.
+type goLocals struct {
+	add    func(ident *ast.Ident, exported bool)
+	scopes []map[string]bool
+}
+
+func (l *goLocals) push() { l.scopes = append(l.scopes, make(map[string]bool)) }
+func (l *goLocals) pop()  { l.scopes = l.scopes[:len(l.scopes)-1] }
+
+// declare adds ident, declared in the innermost scope. With redeclare, it
+// is skipped if it is already declared in that scope.
+func (l *goLocals) declare(ident *ast.Ident, redeclare bool) {
+	if ident == nil || ident.Name == "_" {
+		return
+	}
+	scope := l.scopes[len(l.scopes)-1]
+	if redeclare && scope[ident.Name] {
+		return
+	}
+	scope[ident.Name] = true
+	l.add(ident, false)
+}
+
+// fields declares the names of parameters, results or receivers in the
+// innermost scope.
+func (l *goLocals) fields(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			l.declare(name, false)
+		}
+		goTypeIdents(field.Type, false, l.add)
+	}
+}
+
+// walk adds the identifiers declared in node, opening a scope for each block
+// and for the implicit blocks of statements.
+func (l *goLocals) walk(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			l.push()
+			for _, stmt := range n.List {
+				l.walk(stmt)
+			}
+			l.pop()
+		case *ast.IfStmt:
+			l.push()
+			l.walk(n.Init)
+			l.walk(n.Cond)
+			l.walk(n.Body)
+			l.walk(n.Else)
+			l.pop()
+		case *ast.ForStmt:
+			l.push()
+			l.walk(n.Init)
+			l.walk(n.Cond)
+			l.walk(n.Post)
+			l.walk(n.Body)
+			l.pop()
+		case *ast.RangeStmt:
+			l.walk(n.X)
+			l.push()
+			if n.Tok == token.DEFINE {
+				key, _ := n.Key.(*ast.Ident)
+				value, _ := n.Value.(*ast.Ident)
+				l.declare(key, false)
+				l.declare(value, false)
+			}
+			l.walk(n.Body)
+			l.pop()
+		case *ast.SwitchStmt:
+			l.push()
+			l.walk(n.Init)
+			l.walk(n.Tag)
+			l.walk(n.Body)
+			l.pop()
+		case *ast.TypeSwitchStmt:
+			l.push()
+			l.walk(n.Init)
+			l.walk(n.Assign)
+			l.walk(n.Body)
+			l.pop()
+		case *ast.CaseClause:
+			l.push()
+			for _, expr := range n.List {
+				l.walk(expr)
+			}
+			for _, stmt := range n.Body {
+				l.walk(stmt)
+			}
+			l.pop()
+		case *ast.CommClause:
+			l.push()
+			l.walk(n.Comm)
+			for _, stmt := range n.Body {
+				l.walk(stmt)
+			}
+			l.pop()
+		case *ast.FuncLit:
+			l.push()
+			l.fields(n.Type.Params)
+			l.fields(n.Type.Results)
+			for _, stmt := range n.Body.List {
+				l.walk(stmt)
+			}
+			l.pop()
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				return true
+			}
+			for _, lhs := range n.Lhs {
+				ident, _ := lhs.(*ast.Ident)
+				l.declare(ident, true)
+			}
+			for _, rhs := range n.Rhs {
+				l.walk(rhs)
+			}
+		case *ast.TypeSpec:
+			l.declare(n.Name, false)
+			l.push()
+			l.fields(n.TypeParams)
+			l.pop()
+			goTypeIdents(n.Type, false, l.add)
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				l.declare(name, false)
+			}
+			if n.Type != nil {
+				goTypeIdents(n.Type, false, l.add)
+			}
+			for _, value := range n.Values {
+				l.walk(value)
+			}
+		case *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+			goTypeIdents(n.(ast.Expr), false, l.add)
+		case *ast.LabeledStmt:
+			l.add(n.Label, false)
+			return true
+		default:
+			return true
+		}
+		return false
+	})
+}
@@ -1,6 +1,16 @@
 package read
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
 
 func TestReadDirGo(t *testing.T) {
 	path := "testdata/golang"
@@ -12,21 +22,269 @@ func TestReadDirGo(t *testing.T) {
 		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
 	}
 	pkg := pkgs[0]
-	if got, want := pkg.Name, "gopher"; got != want {
-		t.Errorf("pkg.Name = %q, want %q", got, want)
+	if got, want := pkg.Name, "/testdata/golang"; !strings.HasSuffix(got, want) {
+		t.Errorf("pkg.Name = %q, want import path ending in %q", got, want)
 	}
 	idents := []string{
 		"Age", "fmt", "g", "gopher", "Gopher", "int", "Name",
 		"Sprintf", "string", "String",
 	}
-	if !haveSameElements(pkg.Identifiers, idents) {
-		t.Errorf("pkg.Identifiers = %#v, want %#v", pkg.Identifiers, idents)
+	for i := 1; i < len(pkg.Identifiers); i++ {
+		if pkg.Identifiers[i-1] >= pkg.Identifiers[i] {
+			t.Fatalf("pkg.Identifiers = %#v, want sorted without duplicates", pkg.Identifiers)
+		}
+	}
+	// and the names exported by fmt
+	idents = append(idents, "Errorf", "Formatter", "Stringer", "Width")
+	known := make(map[string]bool)
+	for _, ident := range pkg.Identifiers {
+		known[ident] = true
+	}
+	for _, ident := range idents {
+		if !known[ident] {
+			t.Errorf("pkg.Identifiers = %#v, want %q in it", pkg.Identifiers, ident)
+		}
 	}
 	if got, want := len(pkg.Documentation), 3; got != want {
 		t.Errorf("len(pkg.Documentation) = %d, want %d", got, want)
 	}
 }
 
+func TestLoadGo(t *testing.T) {
+	for _, tt := range []struct {
+		format  GoFormat
+		pattern string
+		want    map[string][]string
+	}{
+		{
+			GoFormat{}, "./testdata/gotags",
+			map[string][]string{
+				"/testdata/gotags": {
+					"Package gotags has files for some build tags.",
+					"tested is only built with tests.",
+				},
+				"/testdata/gotags_test": {"Examples are in an external test package."},
+			},
+		},
+		{
+			GoFormat{Tags: []string{"extra"}}, "./testdata/gotags",
+			map[string][]string{
+				"/testdata/gotags": {
+					"Extra is only built with the extra tag.",
+					"Package gotags has files for some build tags.",
+					"tested is only built with tests.",
+				},
+				"/testdata/gotags_test": {"Examples are in an external test package."},
+			},
+		},
+		{
+			GoFormat{}, "./testdata/gotags/cmd/...",
+			map[string][]string{
+				"/testdata/gotags/cmd/tagger":   {"Command tagger is a main package."},
+				"/testdata/gotags/cmd/untagger": {"Command untagger is another main package."},
+			},
+		},
+	} {
+		pkgs, err := tt.format.Load("", tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string][]string)
+		for _, pkg := range pkgs {
+			i := strings.Index(pkg.Name, "/testdata/")
+			if i < 0 {
+				t.Fatalf("Load(%q) read package %q, want import path in testdata", tt.pattern, pkg.Name)
+			}
+			var texts []string
+			for _, text := range pkg.Documentation {
+				texts = append(texts, text.Content)
+			}
+			sort.Strings(texts)
+			got[pkg.Name[i:]] = texts
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%#v.Load(%q) read %q, want %q", tt.format, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestReadTreeGo(t *testing.T) {
+	format := GoFormat{Tags: []string{"extra"}}
+	tree, err := format.ReadTree("testdata/gotags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tree.(goTree); !ok {
+		t.Fatalf("ReadTree returned %T, want packages loaded at once", tree)
+	}
+	for _, dir := range []string{"testdata/gotags", "testdata/gotags/cmd", "testdata/gotags/cmd/tagger", "testdata/godoc"} {
+		got, err := tree.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := format.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(docContents(got), docContents(want)) {
+			t.Errorf("ReadDir(%q) from the tree = %q, want %q", dir, docContents(got), docContents(want))
+		}
+	}
+}
+
+// docContents returns the sorted contents of the documentation of pkgs, by
+// package name.
+func docContents(pkgs []*types.Package) map[string][]string {
+	r := make(map[string][]string)
+	for _, pkg := range pkgs {
+		var texts []string
+		for _, text := range pkg.Documentation {
+			texts = append(texts, text.Content)
+		}
+		sort.Strings(texts)
+		r[pkg.Name] = texts
+	}
+	return r
+}
+
+func TestLoadGoOutsideModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"a.go":                "package nomod\n\n// Hello is outside modules.\nfunc Hello() {}\n",
+		"never.go":            "//go:build never\n\npackage nomod\n\n// Never is excluded.\nvar Never = 1\n",
+		"a_test.go":           "package nomod_test\n\n// Test package.\nvar _ = 1\n",
+		"sub/b.go":            "package sub\n\n// Sub package.\nvar X = 1\n",
+		"sub/testdata/c.go":   "package c\n\n// Ignored.\nvar Y = 1\n",
+		"empty/deeper/README": "no Go files above\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		dir, pattern string
+		want         map[string][]string
+	}{
+		{dir, ".", map[string][]string{
+			"nomod":      {"Hello is outside modules."},
+			"nomod_test": {"Test package."},
+		}},
+		{dir, "./...", map[string][]string{
+			"nomod":      {"Hello is outside modules."},
+			"nomod_test": {"Test package."},
+			"sub":        {"Sub package."},
+		}},
+		{filepath.Join(dir, "empty"), ".", map[string][]string{}},
+	} {
+		pkgs, err := GoFormat{}.Load(tt.dir, tt.pattern)
+		if err != nil {
+			t.Fatalf("Load(%q, %q) returned error: %v", tt.dir, tt.pattern, err)
+		}
+		got := make(map[string][]string)
+		for _, pkg := range pkgs {
+			for _, text := range pkg.Documentation {
+				got[pkg.Name] = append(got[pkg.Name], text.Content)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Load(%q, %q) read %q, want %q", tt.dir, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestReadDirGoNoFiles(t *testing.T) {
+	pkgs, err := GoFormat{}.ReadDir("testdata/markdown")
+	if err != nil || len(pkgs) != 0 {
+		t.Errorf("ReadDir of a directory without Go files = %v, %v, want no packages and no error", pkgs, err)
+	}
+	pkgs, err = NewRecursive(GoFormat{}).ReadDir("testdata/gotags")
+	if err != nil || len(pkgs) == 0 {
+		t.Errorf("recursive ReadDir through directories without Go files = %d packages, %v, want packages and no error", len(pkgs), err)
+	}
+}
+
+func TestReadDirGoDocComments(t *testing.T) {
+	path := "testdata/godoc"
+	pkgs, err := GoFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(path, "doc.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type text struct {
+		content      string
+		line, column int
+	}
+	var got []text
+	for _, doc := range pkgs[0].Documentation {
+		got = append(got, text{doc.Content, doc.Position.Line, doc.Position.Column})
+		// only masked bytes differ from the source
+		src := b[doc.Position.Offset : doc.Position.Offset+len(doc.Content)]
+		for i := range src {
+			if c := doc.Content[i]; c != ' ' && c != src[i] {
+				t.Errorf("text %q does not match the source %q", doc.Content, src)
+				break
+			}
+		}
+	}
+	want := []text{
+		{"Package godoc has doc comments with structure.", 1, 4},
+		{"  Usage", 3, 4},
+		{"Call         on the text, like                  does, as described in\n   " +
+			"                           and the [Go spec]:", 5, 4},
+		{"Results are:\n     - first item\n     - second item", 10, 4},
+		{"Parse splits text.", 20, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("texts = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadDirGoImportedIdents(t *testing.T) {
+	path := "testdata/goimports"
+	pkgs, err := GoFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	count := make(map[string]int)
+	for _, ident := range pkgs[0].Identifiers {
+		count[ident]++
+	}
+	// package names, declarations, methods and fields
+	for _, ident := range []string{"json", "RawMessage", "http", "HandlerFunc", "ServeHTTP", "Request", "Header"} {
+		if count[ident] == 0 {
+			t.Errorf("pkg.Identifiers has no %q", ident)
+		}
+	}
+	// imported identifiers are not duplicated
+	for _, ident := range []string{"Marshal", "StatusOK", "Cookie"} {
+		if count[ident] != 1 {
+			t.Errorf("pkg.Identifiers has %d %q, want 1", count[ident], ident)
+		}
+	}
+	// unexported names are not known
+	if count["errMissingHost"] > 0 {
+		t.Errorf("pkg.Identifiers has unexported %q", "errMissingHost")
+	}
+}
+
 func haveSameElements(a, b []string) bool {
 	m := make(map[string]bool)
 	for _, k := range a {
@@ -46,3 +304,138 @@ func haveSameElements(a, b []string) bool {
 	}
 	return true
 }
+
+func TestReadDirGoStrings(t *testing.T) {
+	path := "testdata/gostrings"
+	pkgs, err := GoFormat{Strings: true}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	src, err := ioutil.ReadFile(filepath.Join(path, "messages.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		content string
+		// words in content and their source
+		words [][2]string
+	}{
+		{"missing confguration", [][2]string{{"confguration", "confguration"}}},
+		{"Helo,   !\n\tWelcome to café      !", [][2]string{{"Helo", "Helo"}, {"Welcome", "Welcome"}, {"café", `caf\u00e9`}}},
+		{"gophr", [][2]string{{"gophr", "gophr"}}},
+		{"Usage: gretings [options]\n  --verbose  Print more detials", [][2]string{{"gretings", "gretings"}, {"detials", "detials"}}},
+	} {
+		if i >= len(pkgs[0].Documentation) {
+			t.Fatalf("got %d texts, want %d", len(pkgs[0].Documentation), i+1)
+		}
+		text := pkgs[0].Documentation[i]
+		if text.Content != want.content {
+			t.Errorf("texts[%d].Content = %q, want %q", i, text.Content, want.content)
+			continue
+		}
+		for _, word := range want.words {
+			offset := strings.Index(text.Content, word[0])
+			begin := text.Position.Offset + text.SourceOffset(offset)
+			end := text.Position.Offset + text.SourceOffset(offset+len(word[0]))
+			if got := string(src[begin:end]); got != word[1] {
+				t.Errorf("source of %q in texts[%d] = %q, want %q", word[0], i, got, word[1])
+			}
+		}
+	}
+	if got, want := len(pkgs[0].Documentation), 4; got != want {
+		t.Errorf("got %d texts, want %d", got, want)
+	}
+}
+
+func TestReadDirGoIdentifiers(t *testing.T) {
+	path := "testdata/goidents"
+	pkgs, err := GoFormat{Identifiers: true}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	type ident struct {
+		name         string
+		line, column int
+		exported     bool
+	}
+	var got []ident
+	for _, text := range pkgs[0].Documentation {
+		if text.Identifier {
+			got = append(got, ident{text.Content, text.Position.Line, text.Position.Column, text.Exported})
+		}
+	}
+	want := []ident{
+		{"RecieveBuffer", 5, 6, true},
+		{"Data", 6, 2, true},
+		{"maxRetires", 7, 2, false},
+		{"DefaultSize", 10, 7, true},
+		{"Fill", 12, 25, true},
+		{"b", 12, 7, false},
+		{"src", 12, 30, false},
+		{"n", 12, 43, false},
+		{"i", 13, 6, false},
+		{"c", 13, 9, false},
+		{"lenght", 18, 2, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("identifiers = %v, want %v", got, want)
+	}
+}
+
+func TestReadDirGoExportedIdentifiers(t *testing.T) {
+	path := "testdata/goexported"
+	pkgs, err := GoFormat{Identifiers: true}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	type ident struct {
+		name     string
+		line     int
+		exported bool
+	}
+	var got []ident
+	for _, text := range pkgs[0].Documentation {
+		if text.Identifier {
+			got = append(got, ident{text.Content, text.Position.Line, text.Exported})
+		}
+	}
+	want := []ident{
+		{"Buffer", 5, true},
+		{"Size", 6, true},
+		{"Options", 7, true},
+		{"Verbose", 8, true},
+		{"inner", 10, false},
+		{"Hidden", 11, false},
+		{"Read", 15, true},
+		{"b", 15, false},
+		{"Dst", 15, false},
+		{"N", 15, false},
+		{"Err", 15, false},
+		// err is redeclared, not declared again, in line 17
+		{"n", 16, false},
+		{"err", 16, false},
+		{"m", 17, false},
+		// n and err are declared again in a new scope
+		{"n", 19, false},
+		{"err", 19, false},
+		{"buffer", 25, false},
+		{"Size", 26, false},
+		{"Read", 29, false},
+		{"b", 29, false},
+		{"Reader", 32, true},
+		{"Read", 33, true},
+		{"P", 33, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("identifiers = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package read
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnoreFiles are the names of the files with ignore rules honoured
+// while walking directories.
+var DefaultIgnoreFiles = []string{".gitignore", ".typokillerignore"}
+
+// An ignoreRule is a pattern of an ignore file, with the syntax of .gitignore.
+type ignoreRule struct {
+	base     string // directory of the ignore file
+	pattern  string
+	negate   bool // the pattern starts with '!'
+	dirOnly  bool // the pattern ends with '/'
+	anchored bool // the pattern is relative to base
+}
+
+// ignoreList is a list of ignore rules. Later rules take precedence.
+type ignoreList []ignoreRule
+
+// parseIgnoreRule parses a line of an ignore file in dir. It returns false for
+// blank lines and comments.
+func parseIgnoreRule(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: dir}
+	switch {
+	case line[0] == '!':
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// match returns true if the rule matches the absolute path name.
+func (r ignoreRule) match(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments returns true if the slash-separated segments of a path match
+// those of a pattern, where "**" matches any number of segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// a trailing "/**" matches everything inside
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// ignored returns true if the last rule that matches the absolute path name
+// is not a negation.
+func (l ignoreList) ignored(name string, isDir bool) bool {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i].match(name, isDir) {
+			return !l[i].negate
+		}
+	}
+	return false
+}
+
+// readIgnoreFile appends to l the rules in the file named name in dir, if it
+// exists. dir must be absolute.
+func (l ignoreList) readIgnoreFile(dir, name string) (ignoreList, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	defer f.Close()
+	// do not modify the backing array shared with the parent directory
+	l = l[:len(l):len(l)]
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+			l = append(l, rule)
+		}
+	}
+	return l, scanner.Err()
+}
+
+// parentIgnores returns the rules of the ignore files in the parent
+// directories of dir, up to the root of the Git repository that contains it.
+// It returns nil if dir is not in a Git repository.
+func parentIgnores(dir string, names []string) (ignoreList, error) {
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		// dir is the root of the repository
+		return nil, nil
+	}
+	var parents []string
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		parents = append(parents, d)
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if d == filepath.Dir(d) {
+			// not in a repository
+			return nil, nil
+		}
+	}
+	var l ignoreList
+	for i := len(parents) - 1; i >= 0; i-- {
+		for _, name := range names {
+			var err error
+			if l, err = l.readIgnoreFile(parents[i], name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
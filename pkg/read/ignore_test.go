@@ -0,0 +1,97 @@
+package read
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	base := "/repo"
+	for _, tt := range []struct {
+		line  string
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"*.pb.go", "/repo/api/v1/types.pb.go", false, true},
+		{"*.pb.go", "/repo/api/v1/types.go", false, false},
+		{"*.pb.go", "/other/types.pb.go", false, false},
+		{"mocks/", "/repo/pkg/mocks", true, true},
+		{"mocks/", "/repo/pkg/mocks", false, false},
+		{"/bindata.go", "/repo/bindata.go", false, true},
+		{"/bindata.go", "/repo/pkg/bindata.go", false, false},
+		{"pkg/*.go", "/repo/pkg/a.go", false, true},
+		{"pkg/*.go", "/repo/pkg/sub/a.go", false, false},
+		{"**/gen", "/repo/gen", true, true},
+		{"**/gen", "/repo/a/b/gen", true, true},
+		{"a/**/b", "/repo/a/b", true, true},
+		{"a/**/b", "/repo/a/x/y/b", true, true},
+		{"a/**", "/repo/a/x/y", false, true},
+		{"a/**", "/repo/a", true, false},
+		{`\#notes`, "/repo/#notes", false, true},
+		{"trailing   ", "/repo/trailing", false, true},
+		{`space\ `, "/repo/space ", false, true},
+	} {
+		rule, ok := parseIgnoreRule(base, tt.line)
+		if !ok {
+			t.Errorf("parseIgnoreRule(%q, %q) returned false", base, tt.line)
+			continue
+		}
+		if got := rule.match(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("rule %q match(%q, %v) = %v, want %v", tt.line, tt.name, tt.isDir, got, tt.want)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := parseIgnoreRule(base, line); ok {
+			t.Errorf("parseIgnoreRule(%q, %q) returned true, want false", base, line)
+		}
+	}
+}
+
+func TestRecursiveIgnoreFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, content := range map[string]string{
+		".gitignore":            "*.log\nbuild/\n",
+		".typokillerignore":     "generated.txt\n",
+		"README":                "Readme.\n",
+		"debug.log":             "Not text.\n",
+		"generated.txt":         "Generated.\n",
+		"build/out.txt":         "Build output.\n",
+		"docs/.gitignore":       "*.txt\n!keep.txt\n",
+		"docs/keep.txt":         "Kept.\n",
+		"docs/skip.txt":         "Skipped.\n",
+		"docs/sub/deep.txt":     "Skipped too.\n",
+		"docs/sub/keep.txt":     "Kept too.\n",
+		"other/generated.txt":   "Generated.\n",
+		"other/not-ignored.txt": "Not ignored.\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs, err := NewRecursive(TextFormat{}).ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range pkgs {
+		for _, text := range pkg.Documentation {
+			got = append(got, filepath.ToSlash(strings.TrimPrefix(text.Position.Filename, root+string(filepath.Separator))))
+		}
+	}
+	want := []string{"README", "docs/keep.txt", "docs/sub/keep.txt", "other/not-ignored.txt"}
+	if len(got) != len(want) || !haveSameElements(got, want) {
+		t.Errorf("ReadDir read %q, want %q", got, want)
+	}
+}
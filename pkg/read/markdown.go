@@ -0,0 +1,385 @@
+package read
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// MarkdownFormat can read documentation from Markdown files.
+type MarkdownFormat struct{}
+
+// ReadDir extracts Markdown-formatted documentation from files in path.
+// It does not recurse into subdirectories.
+func (f MarkdownFormat) ReadDir(path string) ([]*types.Package, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var r []*types.Package
+	for _, entry := range entries {
+		if f.IsMarkdownFile(entry) {
+			p, err := f.ReadFile(filepath.Join(path, entry.Name()), entry)
+			if err != nil {
+				return r, err
+			}
+			r = append(r, p)
+		}
+	}
+	return r, nil
+}
+
+// ReadFile extracts prose blocks from Markdown files: paragraphs, headings,
+// list items, blockquotes and table cells. Code blocks, HTML blocks and front
+// matter are skipped. Within each block, inline code, link destinations and
+// HTML tags are replaced with spaces, so that offsets still match the file.
+func (f MarkdownFormat) ReadFile(path string, fi os.FileInfo) (*types.Package, error) {
+	doc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &types.Package{Name: fi.Name()}
+	for _, block := range markdownBlocks(doc) {
+		p.Documentation = append(p.Documentation, &types.Text{
+			Content:  maskMarkdownInline(doc[block.begin:block.end]),
+			Position: offsetPosition(path, doc, block.begin),
+		})
+	}
+	return p, nil
+}
+
+// IsMarkdownFile returns true for Markdown files, false otherwise.
+func (f MarkdownFormat) IsMarkdownFile(fi os.FileInfo) bool {
+	if !fi.Mode().IsRegular() {
+		return false
+	}
+	switch filepath.Ext(fi.Name()) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// An mdLine is a line of a Markdown document, with its blockquote markers
+// already consumed.
+type mdLine struct {
+	begin, end int // the line, without line ending
+	quote      int // blockquote depth
+	indent     int // indentation width of the content after blockquote markers
+	text       int // offset of the first non-space character after markers
+}
+
+var (
+	mdFence        = regexp.MustCompile("^(```+|~~~+)")
+	mdHeading      = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	mdBreak        = regexp.MustCompile(`^(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdSetext       = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	mdListItem     = regexp.MustCompile(`^(?:[-*+]|[0-9]{1,9}[.)])(?:[ \t]+(?:\[[ xX]\][ \t]+)?|$)`)
+	mdHTMLBlock    = regexp.MustCompile(`^<(?:[A-Za-z/!?])`)
+	mdLinkRefDef   = regexp.MustCompile(`^\[[^\]]+\]:`)
+	mdTableDivider = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// splitMarkdownLines splits doc into lines, consuming blockquote markers.
+func splitMarkdownLines(doc []byte) []mdLine {
+	var lines []mdLine
+	for begin := 0; begin < len(doc); {
+		end := len(doc)
+		next := len(doc)
+		if i := bytes.IndexByte(doc[begin:], '\n'); i >= 0 {
+			end, next = begin+i, begin+i+1
+		}
+		if end > begin && doc[end-1] == '\r' {
+			end--
+		}
+		l := mdLine{begin: begin, end: end}
+		i := begin
+		for {
+			j := i
+			for j < end && j-i < 3 && doc[j] == ' ' {
+				j++
+			}
+			if j < end && doc[j] == '>' {
+				l.quote++
+				i = j + 1
+				if i < end && doc[i] == ' ' {
+					i++
+				}
+				continue
+			}
+			break
+		}
+		l.text = i
+		for l.text < end && (doc[l.text] == ' ' || doc[l.text] == '\t') {
+			if doc[l.text] == '\t' {
+				l.indent += 4 - l.indent%4
+			} else {
+				l.indent++
+			}
+			l.text++
+		}
+		lines = append(lines, l)
+		begin = next
+	}
+	return lines
+}
+
+// markdownFrontMatter returns the number of lines of YAML or TOML front matter
+// at the beginning of a document.
+func markdownFrontMatter(doc []byte, lines []mdLine) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	delim := string(doc[lines[0].begin:lines[0].end])
+	if delim != "---" && delim != "+++" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		l := string(doc[lines[i].begin:lines[i].end])
+		if l == delim || (delim == "---" && l == "...") {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// markdownBlocks returns the prose blocks of a Markdown document.
+func markdownBlocks(doc []byte) []span {
+	lines := splitMarkdownLines(doc)
+	var (
+		blocks     []span
+		cur        *span // the paragraph, heading or list item being read
+		curQuote   int
+		fence      []byte // the opening code fence, if in a fenced code block
+		html       bool   // in an HTML block
+		table      bool   // in a table
+		listIndent = -1   // content indentation of the current list item
+		prevBlank  = true
+	)
+	closeBlock := func() {
+		if cur != nil {
+			blocks = append(blocks, *cur)
+			cur = nil
+		}
+	}
+	for n := markdownFrontMatter(doc, lines); n < len(lines); n++ {
+		l := lines[n]
+		text := doc[l.text:l.end]
+		blank := len(text) == 0
+		switch {
+		case fence != nil:
+			if l.indent < 4 && bytes.HasPrefix(text, fence) && len(bytes.Trim(text, string(fence[:1])+" \t")) == 0 {
+				fence = nil
+			}
+			continue
+		case html:
+			if blank || bytes.Contains(text, []byte("-->")) {
+				html = false
+			}
+			continue
+		case table && !blank && bytes.IndexByte(text, '|') >= 0:
+			blocks = append(blocks, markdownTableCells(doc, l)...)
+			continue
+		}
+		table = false
+		if blank {
+			closeBlock()
+			prevBlank = true
+			continue
+		}
+		if cur != nil && l.quote != curQuote {
+			closeBlock()
+		}
+		isListItem := l.indent < 4 && mdListItem.Match(text) && !mdBreak.Match(text)
+		if prevBlank && listIndent >= 0 && l.indent < listIndent && !isListItem {
+			listIndent = -1
+		}
+		indent := l.indent
+		if listIndent >= 0 && indent >= listIndent {
+			indent -= listIndent
+		}
+		if cur == nil && indent >= 4 {
+			// indented code block
+			prevBlank = false
+			continue
+		}
+		prevBlank = false
+		switch {
+		case indent < 4 && mdFence.Match(text):
+			closeBlock()
+			fence = mdFence.Find(text)
+			continue
+		case indent < 4 && mdHeading.Match(text):
+			closeBlock()
+			begin := l.text + len(mdHeading.Find(text))
+			if begin < l.end {
+				blocks = append(blocks, span{begin, l.end})
+			}
+			continue
+		case cur != nil && !isListItem && mdSetext.Match(text):
+			// the underline of a setext heading
+			closeBlock()
+			continue
+		case mdBreak.Match(text):
+			closeBlock()
+			continue
+		case cur == nil && mdHTMLBlock.Match(text) && !mdAutolink.Match(text):
+			html = !bytes.HasPrefix(text, []byte("<!--")) || !bytes.Contains(text, []byte("-->"))
+			continue
+		case cur == nil && mdLinkRefDef.Match(text):
+			continue
+		case cur == nil && n+1 < len(lines) && bytes.IndexByte(text, '|') >= 0 &&
+			mdTableDivider.Match(doc[lines[n+1].text:lines[n+1].end]):
+			blocks = append(blocks, markdownTableCells(doc, l)...)
+			table = true
+			n++ // skip the divider
+			continue
+		case isListItem:
+			closeBlock()
+			marker := mdListItem.Find(text)
+			listIndent = l.indent + len(marker)
+			if len(marker) == len(text) {
+				// empty list item, the content starts in the next line
+				continue
+			}
+			cur = &span{l.text + len(marker), l.end}
+			curQuote = l.quote
+			continue
+		}
+		if cur == nil {
+			cur = &span{l.text, l.end}
+			curQuote = l.quote
+		} else {
+			cur.end = l.end
+		}
+	}
+	closeBlock()
+	return blocks
+}
+
+// markdownTableCells returns the non-empty cells of a table row.
+func markdownTableCells(doc []byte, l mdLine) []span {
+	var cells []span
+	begin := l.text
+	add := func(end int) {
+		b, e := begin, end
+		for b < e && (doc[b] == ' ' || doc[b] == '\t') {
+			b++
+		}
+		for e > b && (doc[e-1] == ' ' || doc[e-1] == '\t') {
+			e--
+		}
+		if b < e {
+			cells = append(cells, span{b, e})
+		}
+	}
+	for i := l.text; i < l.end; i++ {
+		switch doc[i] {
+		case '\\':
+			i++
+		case '`':
+			// pipes inside code spans do not split cells
+			if j := bytes.IndexByte(doc[i+1:l.end], '`'); j >= 0 {
+				i += j + 1
+			}
+		case '|':
+			add(i)
+			begin = i + 1
+		}
+	}
+	add(l.end)
+	return cells
+}
+
+var (
+	mdAutolink = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9.+-]*:[^<> ]*>|^<[^<> @]+@[^<> ]+>`)
+	mdHTMLTag  = regexp.MustCompile(`^<!--[\s\S]*?-->|^</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>`)
+)
+
+// maskMarkdownInline returns the content of a block with code spans, link
+// destinations, reference labels, autolinks and HTML tags replaced with
+// spaces. Line breaks are preserved.
+func maskMarkdownInline(b []byte) string {
+	b = append([]byte(nil), b...)
+	mask := func(begin, end int) {
+		for i := begin; i < end; i++ {
+			if b[i] != '\n' && b[i] != '\r' {
+				b[i] = ' '
+			}
+		}
+	}
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '`':
+			n := 1
+			for i+n < len(b) && b[i+n] == '`' {
+				n++
+			}
+			end := -1
+			for j := i + n; j < len(b); j++ {
+				if b[j] != '`' {
+					continue
+				}
+				m := 1
+				for j+m < len(b) && b[j+m] == '`' {
+					m++
+				}
+				if m == n {
+					end = j + m
+					break
+				}
+				j += m - 1
+			}
+			if end < 0 {
+				i += n - 1
+				continue
+			}
+			mask(i, end)
+			i = end - 1
+		case '<':
+			if m := mdAutolink.Find(b[i:]); m != nil {
+				mask(i, i+len(m))
+				i += len(m) - 1
+			} else if m := mdHTMLTag.Find(b[i:]); m != nil {
+				mask(i, i+len(m))
+				i += len(m) - 1
+			}
+		case ']':
+			if i+1 >= len(b) {
+				continue
+			}
+			var open, close byte
+			switch b[i+1] {
+			case '(':
+				open, close = '(', ')'
+			case '[':
+				open, close = '[', ']'
+			default:
+				continue
+			}
+			depth := 0
+			for j := i + 1; j < len(b); j++ {
+				switch b[j] {
+				case '\\':
+					j++
+				case open:
+					depth++
+				case close:
+					depth--
+				}
+				if depth == 0 {
+					mask(i+1, j+1)
+					i = j
+					break
+				}
+			}
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,69 @@
+package read
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadDirMarkdown(t *testing.T) {
+	path := "testdata/markdown"
+	pkgs, err := MarkdownFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	pkg := pkgs[0]
+	if got, want := pkg.Name, "README.md"; got != want {
+		t.Errorf("pkg.Name = %q, want %q", got, want)
+	}
+	if pkg.Identifiers != nil {
+		t.Errorf("pkg.Identifiers = %#v, want nil", pkg.Identifiers)
+	}
+	for i, want := range []struct {
+		content              string
+		offset, line, column int
+	}{
+		{"Markdown is *Writing* Zen", 42, 5, 3},
+		{"_Zen_ in the **art** of writing              with\n[Markdown]                                                          .", 69, 7, 1},
+		{"Use [CommonMark]       for the best Markdown\n> experience.", 192, 10, 3},
+		{"Sample Section", 252, 13, 1},
+		{"item one", 285, 16, 3},
+		{"item      ", 296, 17, 3},
+		{"first     step     ", 311, 19, 4},
+		{"second step", 334, 20, 4},
+		{"Name", 431, 32, 3},
+		{"Description", 438, 32, 10},
+		{"gopher", 477, 34, 3},
+		{"a cute animal", 486, 34, 12},
+	} {
+		if i >= len(pkg.Documentation) {
+			t.Errorf("missing pkg.Documentation[%d], want %q", i, want.content)
+			continue
+		}
+		text := pkg.Documentation[i]
+		if text.Content != want.content {
+			t.Errorf("pkg.Documentation[%d].Content = %q, want %q", i, text.Content, want.content)
+		}
+		if got := text.Position.Offset; got != want.offset {
+			t.Errorf("pkg.Documentation[%d].Offset = %d, want %d", i, got, want.offset)
+		}
+		if got := text.Position.Line; got != want.line {
+			t.Errorf("pkg.Documentation[%d].Line = %d, want %d", i, got, want.line)
+		}
+		if got := text.Position.Column; got != want.column {
+			t.Errorf("pkg.Documentation[%d].Column = %d, want %d", i, got, want.column)
+		}
+	}
+	if got, want := len(pkg.Documentation), 12; got != want {
+		t.Errorf("len(pkg.Documentation) = %d, want %d", got, want)
+	}
+	for _, text := range pkg.Documentation {
+		for _, skipped := range []string{"title", "Hello", "indented", "raw", "commonmark.org", "daringfireball"} {
+			if strings.Contains(text.Content, skipped) {
+				t.Errorf("%q should have been skipped from %q", skipped, text.Content)
+			}
+		}
+	}
+}
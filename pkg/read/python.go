@@ -0,0 +1,390 @@
+package read
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// PythonFormat can read documentation from Python source code.
+type PythonFormat struct{}
+
+// ReadDir extracts documentation metadata from Python files in path.
+// It does not recurse into subdirectories.
+func (f PythonFormat) ReadDir(path string) ([]*types.Package, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var r []*types.Package
+	for _, entry := range entries {
+		if f.IsPythonFile(entry) {
+			p, err := f.ReadFile(filepath.Join(path, entry.Name()), entry)
+			if err != nil {
+				return r, err
+			}
+			r = append(r, p)
+		}
+	}
+	return r, nil
+}
+
+// ReadFile extracts comments and docstrings of a Python module, and the names
+// it defines and imports.
+func (f PythonFormat) ReadFile(path string, fi os.FileInfo) (*types.Package, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &types.Package{Name: strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))}
+	tokens := pyTokenize(src)
+	docstrings := pyDocstrings(tokens)
+	for i, tok := range tokens {
+		if tok.kind == pyComment && !pyIsMagicComment(src, tok) || tok.kind == pyString && docstrings[i] {
+			p.Documentation = append(p.Documentation, &types.Text{
+				Content:  string(src[tok.begin:tok.end]),
+				Position: offsetPosition(path, src, tok.begin),
+			})
+		}
+	}
+	p.Identifiers = pyIdentifiers(tokens)
+	return p, nil
+}
+
+// IsPythonFile returns true for Python source files, false otherwise.
+func (f PythonFormat) IsPythonFile(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && filepath.Ext(fi.Name()) == ".py"
+}
+
+type pyTokenKind int
+
+const (
+	pyName pyTokenKind = iota
+	pyNumber
+	pyString
+	pyComment
+	pyOp
+	pyNewline // end of a logical line
+)
+
+// A pyToken is a token of Python source code.
+type pyToken struct {
+	kind       pyTokenKind
+	begin, end int
+	text       string
+	depth      int // bracket nesting depth before the token
+}
+
+// pyOps are the Python operators and delimiters with more than one character,
+// longest first.
+var pyOps = []string{
+	"**=", "//=", ">>=", "<<=", "...",
+	"->", ":=", "==", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=", "&=",
+	"|=", "^=", "@=", "**", "//", "<<", ">>",
+}
+
+// pyTokenize splits Python source code into tokens. It is lenient: invalid
+// input never fails, it only produces meaningless tokens.
+func pyTokenize(src []byte) []pyToken {
+	var (
+		tokens []pyToken
+		depth  int
+		// whether the current logical line has any token
+		inLine bool
+	)
+	emit := func(kind pyTokenKind, begin, end int) {
+		tokens = append(tokens, pyToken{kind: kind, begin: begin, end: end, text: string(src[begin:end]), depth: depth})
+		if kind != pyComment && kind != pyNewline {
+			inLine = true
+		}
+	}
+	for i := 0; i < len(src); {
+		c, size := utf8.DecodeRune(src[i:])
+		switch {
+		case c == '\n':
+			if depth == 0 && inLine {
+				emit(pyNewline, i, i+1)
+				inLine = false
+			}
+			i++
+		case c == '\\' && i+1 < len(src) && (src[i+1] == '\n' || src[i+1] == '\r'):
+			// explicit line joining
+			i += 2
+			if i < len(src) && src[i-1] == '\r' && src[i] == '\n' {
+				i++
+			}
+		case unicode.IsSpace(c):
+			i += size
+		case c == '#':
+			end := i + bytes.IndexByte(src[i:], '\n')
+			if end < i {
+				end = len(src)
+			}
+			if end > i && src[end-1] == '\r' {
+				end--
+			}
+			emit(pyComment, i, end)
+			i = end
+		case pyStringStart(src[i:]) >= 0:
+			end := pyStringEnd(src, i)
+			emit(pyString, i, end)
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i + size
+			for end < len(src) {
+				c, size := utf8.DecodeRune(src[end:])
+				if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+					break
+				}
+				end += size
+			}
+			emit(pyName, i, end)
+			i = end
+		case unicode.IsDigit(c) || c == '.' && i+1 < len(src) && '0' <= src[i+1] && src[i+1] <= '9':
+			end := i + 1
+			for end < len(src) && (isAlnum(src[end]) || src[end] == '.' || src[end] == '_' ||
+				(src[end] == '-' || src[end] == '+') && (src[end-1] == 'e' || src[end-1] == 'E')) {
+				end++
+			}
+			emit(pyNumber, i, end)
+			i = end
+		default:
+			end := i + size
+			for _, op := range pyOps {
+				if bytes.HasPrefix(src[i:], []byte(op)) {
+					end = i + len(op)
+					break
+				}
+			}
+			switch c {
+			case ')', ']', '}':
+				if depth > 0 {
+					depth--
+				}
+			}
+			emit(pyOp, i, end)
+			switch c {
+			case '(', '[', '{':
+				depth++
+			}
+			i = end
+		}
+	}
+	if inLine {
+		emit(pyNewline, len(src), len(src))
+	}
+	return tokens
+}
+
+func isAlnum(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// pyStringStart returns the length of the prefix of a string literal at the
+// beginning of b, or -1 if b does not start with a string literal.
+func pyStringStart(b []byte) int {
+	for n := 0; n <= 2 && n < len(b); n++ {
+		if b[n] == '\'' || b[n] == '"' {
+			return n
+		}
+		if !strings.ContainsRune("rRbBuUfF", rune(b[n])) {
+			return -1
+		}
+	}
+	return -1
+}
+
+// pyStringEnd returns the offset just after the string literal starting at
+// src[begin:]. Unterminated strings end at the end of the line, or at the end
+// of the source for triple-quoted strings.
+func pyStringEnd(src []byte, begin int) int {
+	i := begin + pyStringStart(src[begin:])
+	quote := src[i]
+	delim := []byte{quote}
+	if bytes.HasPrefix(src[i:], []byte{quote, quote, quote}) {
+		delim = []byte{quote, quote, quote}
+	}
+	for i += len(delim); i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '\n' && len(delim) == 1:
+			return i
+		case bytes.HasPrefix(src[i:], delim):
+			return i + len(delim)
+		}
+	}
+	return len(src)
+}
+
+var pyMagicComment = regexp.MustCompile(`^#!|^#.*coding[:=]`)
+
+// pyIsMagicComment returns true for the shebang line and encoding
+// declarations, which are not prose.
+func pyIsMagicComment(src []byte, tok pyToken) bool {
+	line := bytes.Count(src[:tok.begin], []byte("\n")) + 1
+	return line <= 2 && pyMagicComment.MatchString(tok.text)
+}
+
+// pyDocstrings returns the indices of the tokens that are docstrings: string
+// literals that are the first statement of a module, class or function.
+func pyDocstrings(tokens []pyToken) map[int]bool {
+	r := make(map[int]bool)
+	expect := true // a docstring may come next
+	header := false
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.kind {
+		case pyComment:
+			continue
+		case pyNewline:
+			if !header {
+				continue
+			}
+		}
+		if expect && tok.kind == pyString {
+			// the string must be a statement by itself
+			j := i + 1
+			for j < len(tokens) && (tokens[j].kind == pyString || tokens[j].kind == pyComment) {
+				j++
+			}
+			if j == len(tokens) || tokens[j].kind == pyNewline || tokens[j].text == ";" {
+				r[i] = true
+			}
+		}
+		expect = false
+		switch {
+		case tok.kind == pyName && (tok.text == "def" || tok.text == "class") && tok.depth == 0:
+			header = true
+		case header && tok.kind == pyOp && tok.text == ":" && tok.depth == 0:
+			header = false
+			expect = true
+		}
+	}
+	return r
+}
+
+// pyKeywords are the reserved words of Python.
+var pyKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true,
+}
+
+// pyIdentifiers returns the names defined and imported by Python code:
+// imported modules and names, functions and their parameters, classes,
+// assignment targets, loop variables and names bound with 'as', 'global' or
+// 'nonlocal'.
+func pyIdentifiers(tokens []pyToken) []string {
+	var r []string
+	seen := make(map[string]bool)
+	add := func(tok pyToken) {
+		if tok.kind == pyName && !pyKeywords[tok.text] && !seen[tok.text] {
+			seen[tok.text] = true
+			r = append(r, tok.text)
+		}
+	}
+	// split tokens into logical lines, ignoring comments
+	var line []pyToken
+	for _, tok := range tokens {
+		switch tok.kind {
+		case pyComment:
+			continue
+		case pyNewline:
+			pyLineIdentifiers(line, add)
+			line = line[:0]
+			continue
+		}
+		line = append(line, tok)
+	}
+	pyLineIdentifiers(line, add)
+	return r
+}
+
+// pyLineIdentifiers calls add for every name defined in a logical line.
+func pyLineIdentifiers(line []pyToken, add func(pyToken)) {
+	// skip decorators, 'async' and compound statement keywords that may
+	// precede a simple statement on the same line
+	for len(line) > 0 && line[0].kind == pyName && (line[0].text == "async" || line[0].text == "else" || line[0].text == "try" || line[0].text == "finally") {
+		line = line[1:]
+		if len(line) > 0 && line[0].text == ":" {
+			line = line[1:]
+		}
+	}
+	if len(line) == 0 {
+		return
+	}
+	switch line[0].text {
+	case "import", "from", "global", "nonlocal":
+		for _, tok := range line {
+			add(tok)
+		}
+		return
+	case "class":
+		if len(line) > 1 {
+			add(line[1])
+		}
+		return
+	case "def":
+		if len(line) > 1 {
+			add(line[1])
+		}
+		// parameters follow '(', ',', '*' or '**' at the depth of the
+		// parameter list
+		for i := 3; i < len(line); i++ {
+			prev := line[i-1]
+			if line[i].depth == 1 && prev.kind == pyOp && (prev.text == "(" || prev.text == "," || prev.text == "*" || prev.text == "**") {
+				add(line[i])
+			}
+		}
+		return
+	case "for":
+		for _, tok := range line[1:] {
+			if tok.text == "in" && tok.depth == 0 {
+				break
+			}
+			add(tok)
+		}
+		// the loop body may follow on the same line
+	}
+	for i, tok := range line {
+		if i+1 < len(line) && (tok.text == "as" || line[i+1].text == ":=") {
+			if tok.text == "as" {
+				add(line[i+1])
+			} else {
+				add(tok)
+			}
+		}
+	}
+	// assignment targets are before the last '=' at depth 0, or before ':'
+	// in annotated assignments
+	last := -1
+	for i, tok := range line {
+		if tok.kind == pyOp && tok.text == "=" && tok.depth == 0 {
+			last = i
+		}
+	}
+	if last < 0 {
+		return
+	}
+	for _, tok := range line[:last] {
+		if tok.kind == pyOp && tok.text == ":" && tok.depth == 0 {
+			break
+		}
+		if tok.depth == 0 || tok.depth == 1 && (line[0].text == "(" || line[0].text == "[") {
+			add(tok)
+		}
+	}
+}
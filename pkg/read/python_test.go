@@ -0,0 +1,58 @@
+package read
+
+import "testing"
+
+func TestReadDirPython(t *testing.T) {
+	path := "testdata/python"
+	pkgs, err := PythonFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 1; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	pkg := pkgs[0]
+	if got, want := pkg.Name, "gopher"; got != want {
+		t.Errorf("pkg.Name = %q, want %q", got, want)
+	}
+	idents := []string{
+		"os", "path", "osp", "collections", "OrderedDict", "namedtuple",
+		"nt", "MAX_AGE", "Gopher", "__init__", "self", "name", "age", "args",
+		"kwargs", "greeting", "__str__", "hello", "gopher", "i", "c", "f",
+	}
+	if !haveSameElements(pkg.Identifiers, idents) {
+		t.Errorf("pkg.Identifiers = %#v, want %#v", pkg.Identifiers, idents)
+	}
+	for i, want := range []struct {
+		content              string
+		offset, line, column int
+	}{
+		{`"""Module gopher is just a dummy module for testing typokiller."""`, 46, 3, 1},
+		{"# gophers do not live longer", 231, 8, 15},
+		{"'''A Gopher is a cute animal.'''", 288, 12, 5},
+		{"\"\"\"Create a new Gopher.\n\n        The name is required.\n        \"\"\"", 384, 15, 9},
+		{`"Return a string representation."`, 552, 22, 24},
+		{"# say hello to a Gophr", 629, 26, 5},
+	} {
+		if i >= len(pkg.Documentation) {
+			t.Errorf("missing pkg.Documentation[%d], want %q", i, want.content)
+			continue
+		}
+		text := pkg.Documentation[i]
+		if text.Content != want.content {
+			t.Errorf("pkg.Documentation[%d].Content = %q, want %q", i, text.Content, want.content)
+		}
+		if got := text.Position.Offset; got != want.offset {
+			t.Errorf("pkg.Documentation[%d].Offset = %d, want %d", i, got, want.offset)
+		}
+		if got := text.Position.Line; got != want.line {
+			t.Errorf("pkg.Documentation[%d].Line = %d, want %d", i, got, want.line)
+		}
+		if got := text.Position.Column; got != want.column {
+			t.Errorf("pkg.Documentation[%d].Column = %d, want %d", i, got, want.column)
+		}
+	}
+	if got, want := len(pkg.Documentation), 6; got != want {
+		t.Errorf("len(pkg.Documentation) = %d, want %d", got, want)
+	}
+}
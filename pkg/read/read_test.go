@@ -4,7 +4,7 @@ import "testing"
 
 func TestBadPath(t *testing.T) {
 	path := "testdata/bad/path"
-	for _, dirReader := range []DirReader{GoFormat{}, AsciiDocFormat{}} {
+	for _, dirReader := range []DirReader{GoFormat{}, AsciiDocFormat{}, MarkdownFormat{}, TextFormat{}, PythonFormat{}, DiffFormat{}, WikipediaFormat{}, AutoFormat{}} {
 		_, err := dirReader.ReadDir(path)
 		if err == nil {
 			t.Errorf("%#v.ReadDir(%q) returned err=%v, want nil", dirReader, path, err)
@@ -0,0 +1,22 @@
+// Package godoc has doc comments with structure.
+//
+// # Usage
+//
+// Call [Parse] on the text, like [strings.Fields] does, as described in
+// https://go.dev/doc/comment and the [Go spec]:
+//
+//	words := godoc.Parse("some text")
+//
+// Results are:
+//   - first item
+//   - second item
+//
+// [Go spec]: https://go.dev/ref/spec
+package godoc
+
+//go:generate stringer -type=Kind
+
+/*
+Parse splits text.
+*/
+func Parse(text string) []string { return nil } //nolint:unused
@@ -0,0 +1,34 @@
+// Package exported has names that are exported, and others that only look so.
+package exported
+
+// Buffer is exported, and so are its exported fields and methods.
+type Buffer struct {
+	Size    int
+	Options struct {
+		Verbose bool
+	}
+	inner struct {
+		Hidden bool
+	}
+}
+
+func (b *Buffer) Read(Dst []byte) (N int, Err error) {
+	n, err := len(Dst), error(nil)
+	m, err := n, err
+	if err != nil {
+		n, err := 0, err
+		_, _ = n, err
+	}
+	return m, err
+}
+
+type buffer struct {
+	Size int
+}
+
+func (b buffer) Read() {}
+
+// Reader is implemented by Buffer.
+type Reader interface {
+	Read(P []byte) (int, error)
+}
@@ -0,0 +1,20 @@
+// Package buffer has misspelled names.
+package buffer
+
+// RecieveBuffer holds received data.
+type RecieveBuffer struct {
+	Data       []byte
+	maxRetires int
+}
+
+const DefaultSize = 512
+
+func (b *RecieveBuffer) Fill(src []byte) (n int) {
+	for i, c := range src {
+		b.Data = append(b.Data, c)
+		n = i
+	}
+	_ = n
+	lenght := len(b.Data)
+	return lenght
+}
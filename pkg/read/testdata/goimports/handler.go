@@ -0,0 +1,15 @@
+// Package goimports mentions the APIs of the packages it imports.
+package goimports
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler decodes the body of requests into a json.RawMessage, and serves it
+// as a http.HandlerFunc, with ServeHTTP reading the Header of each Request.
+var Handler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+	var msg json.RawMessage
+	json.NewDecoder(r.Body).Decode(&msg)
+	w.Write(msg)
+}
@@ -0,0 +1,19 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+)
+
+type config struct {
+	Name string `json:"name"`
+}
+
+var errMissing = errors.New("missing confguration")
+
+func greet(name string) string {
+	return fmt.Sprintf("Helo, %s!\n\tWelcome to caf\u00e9 %-10q\x21", name, "gophr")
+}
+
+const usage = `Usage: gretings [options]
+  --verbose  Print more detials`
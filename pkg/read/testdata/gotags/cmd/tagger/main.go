@@ -0,0 +1,4 @@
+// Command tagger is a main package.
+package main
+
+func main() {}
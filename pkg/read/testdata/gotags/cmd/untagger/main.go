@@ -0,0 +1,4 @@
+// Command untagger is another main package.
+package main
+
+func main() {}
@@ -0,0 +1,3 @@
+package gotags_test
+
+// Examples are in an external test package.
@@ -0,0 +1,6 @@
+//go:build extra
+
+package gotags
+
+// Extra is only built with the extra tag.
+const Extra = true
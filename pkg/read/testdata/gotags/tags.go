@@ -0,0 +1,2 @@
+// Package gotags has files for some build tags.
+package gotags
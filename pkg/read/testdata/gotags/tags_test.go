@@ -0,0 +1,4 @@
+package gotags
+
+// tested is only built with tests.
+const tested = true
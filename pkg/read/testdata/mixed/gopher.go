@@ -0,0 +1,2 @@
+// Package gopher is a Go package.
+package gopher
@@ -0,0 +1,30 @@
+#!/usr/bin/env python
+# -*- coding: utf-8 -*-
+"""Module gopher is just a dummy module for testing typokiller."""
+import os.path as osp
+from collections import (OrderedDict,
+                         namedtuple as nt)
+
+MAX_AGE = 15  # gophers do not live longer
+
+
+class Gopher(object):
+    '''A Gopher is a cute animal.'''
+
+    def __init__(self, name, age=0, *args, **kwargs):
+        """Create a new Gopher.
+
+        The name is required.
+        """
+        self.name, self.age = name, age
+        greeting = "not a docstring"
+
+    def __str__(self): "Return a string representation."; return self.name
+
+
+def hello(gopher):
+    # say hello to a Gophr
+    for i, c in enumerate(gopher.name):
+        pass
+    with open(osp.join("a", "b")) as f:
+        return r'\helo' + f.read()
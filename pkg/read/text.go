@@ -0,0 +1,116 @@
+package read
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// TextFormat can read documentation from plain text files.
+type TextFormat struct{}
+
+// ReadDir extracts documentation from plain text files in path.
+// It does not recurse into subdirectories.
+func (f TextFormat) ReadDir(path string) ([]*types.Package, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var r []*types.Package
+	for _, entry := range entries {
+		if f.IsTextFile(entry) {
+			p, err := f.ReadFile(filepath.Join(path, entry.Name()), entry)
+			if err != nil {
+				return r, err
+			}
+			r = append(r, p)
+		}
+	}
+	return r, nil
+}
+
+// ReadFile extracts paragraphs from plain text files. Paragraphs are
+// separated by blank lines, and begin at their first non-space character.
+// Both LF and CRLF line endings are supported.
+func (f TextFormat) ReadFile(path string, fi os.FileInfo) (*types.Package, error) {
+	doc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &types.Package{Name: fi.Name()}
+	for _, paragraph := range textParagraphs(doc) {
+		p.Documentation = append(p.Documentation, &types.Text{
+			Content:  string(doc[paragraph.begin:paragraph.end]),
+			Position: offsetPosition(path, doc, paragraph.begin),
+		})
+	}
+	return p, nil
+}
+
+// textFileNames are conventional names of plain text files without extension.
+var textFileNames = map[string]bool{
+	"AUTHORS":      true,
+	"CHANGELOG":    true,
+	"CHANGES":      true,
+	"CONTRIBUTING": true,
+	"CONTRIBUTORS": true,
+	"COPYING":      true,
+	"HISTORY":      true,
+	"INSTALL":      true,
+	"LICENCE":      true,
+	"LICENSE":      true,
+	"NEWS":         true,
+	"NOTICE":       true,
+	"README":       true,
+	"THANKS":       true,
+	"TODO":         true,
+}
+
+// IsTextFile returns true for plain text files, false otherwise. Plain text
+// files have the .txt extension or a conventional name like README or
+// LICENSE, in any case.
+func (f TextFormat) IsTextFile(fi os.FileInfo) bool {
+	if !fi.Mode().IsRegular() {
+		return false
+	}
+	name := fi.Name()
+	ext := filepath.Ext(name)
+	return ext == ".txt" || (ext == "" && textFileNames[strings.ToUpper(name)])
+}
+
+// textParagraphs returns the paragraphs of doc, without leading spaces and
+// trailing line endings.
+func textParagraphs(doc []byte) []span {
+	var paragraphs []span
+	cur := span{-1, -1}
+	for begin := 0; begin < len(doc); {
+		end, next := len(doc), len(doc)
+		if i := bytes.IndexByte(doc[begin:], '\n'); i >= 0 {
+			end, next = begin+i, begin+i+1
+		}
+		if end > begin && doc[end-1] == '\r' {
+			end--
+		}
+		line := doc[begin:end]
+		if trimmed := bytes.TrimLeft(line, " \t\f\v"); len(bytes.TrimSpace(trimmed)) == 0 {
+			if cur.begin >= 0 {
+				paragraphs = append(paragraphs, cur)
+				cur = span{-1, -1}
+			}
+		} else {
+			if cur.begin < 0 {
+				cur.begin = end - len(trimmed)
+			}
+			cur.end = end
+		}
+		begin = next
+	}
+	if cur.begin >= 0 {
+		paragraphs = append(paragraphs, cur)
+	}
+	return paragraphs
+}
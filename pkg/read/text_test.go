@@ -0,0 +1,56 @@
+package read
+
+import "testing"
+
+func TestReadDirText(t *testing.T) {
+	path := "testdata/text"
+	pkgs, err := TextFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(pkgs), 2; got != want {
+		t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+	}
+	type text struct {
+		content              string
+		offset, line, column int
+	}
+	for i, want := range []struct {
+		name  string
+		texts []text
+	}{
+		{"README", []text{
+			{"Gophers are cute animals.\nThey live in burrows.", 0, 1, 1},
+			{"Indented paragraph\n  with two lines.", 52, 5, 3},
+			{"Last paragraph without line ending.", 91, 8, 1},
+		}},
+		{"notes.txt", []text{
+			{"Windows line endings\r\nare supported.", 0, 1, 1},
+			{"Second paragraph.", 40, 4, 1},
+		}},
+	} {
+		pkg := pkgs[i]
+		if pkg.Name != want.name {
+			t.Errorf("pkgs[%d].Name = %q, want %q", i, pkg.Name, want.name)
+		}
+		if got, want := len(pkg.Documentation), len(want.texts); got != want {
+			t.Errorf("len(%s.Documentation) = %d, want %d", pkg.Name, got, want)
+			continue
+		}
+		for j, want := range want.texts {
+			got := pkg.Documentation[j]
+			if got.Content != want.content {
+				t.Errorf("%s.Documentation[%d].Content = %q, want %q", pkg.Name, j, got.Content, want.content)
+			}
+			if got.Position.Offset != want.offset {
+				t.Errorf("%s.Documentation[%d].Offset = %d, want %d", pkg.Name, j, got.Position.Offset, want.offset)
+			}
+			if got.Position.Line != want.line {
+				t.Errorf("%s.Documentation[%d].Line = %d, want %d", pkg.Name, j, got.Position.Line, want.line)
+			}
+			if got.Position.Column != want.column {
+				t.Errorf("%s.Documentation[%d].Column = %d, want %d", pkg.Name, j, got.Position.Column, want.column)
+			}
+		}
+	}
+}
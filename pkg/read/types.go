@@ -1,9 +1,43 @@
 package read
 
-import "github.com/rhcarvalho/typokiller/pkg/types"
+import (
+	"bytes"
+	"go/token"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
 
 // DirReader is implemented by any value that has a ReadDir method, which
 // defines how to read documentation in a given format.
 type DirReader interface {
 	ReadDir(string) ([]*types.Package, error)
 }
+
+// StreamReader is implemented by readers that can pass packages to a function
+// one at a time, instead of holding all of them in memory.
+type StreamReader interface {
+	ReadStream(path string, fn func(*types.Package) error) error
+}
+
+// TreeReader is implemented by readers that can read a directory and all its
+// subdirectories at once, faster than one directory at a time.
+type TreeReader interface {
+	// ReadTree returns a reader of path and its subdirectories.
+	ReadTree(path string) (DirReader, error)
+}
+
+// offsetPosition returns the position of offset in doc, read from filename.
+// Columns are counted in bytes, like in go/token.
+func offsetPosition(filename string, doc []byte, offset int) token.Position {
+	return token.Position{
+		Filename: filename,
+		Offset:   offset,
+		Line:     bytes.Count(doc[:offset], []byte("\n")) + 1,
+		Column:   offset - bytes.LastIndexByte(doc[:offset], '\n'),
+	}
+}
+
+// A span is a range of bytes [begin, end) of a document.
+type span struct {
+	begin, end int
+}
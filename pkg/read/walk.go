@@ -0,0 +1,181 @@
+package read
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// DefaultExclude lists the directories that are not walked by default.
+var DefaultExclude = []string{".git", "vendor", "Godeps", "testdata"}
+
+// Recursive is a DirReader that reads a directory and all its subdirectories
+// with another DirReader.
+type Recursive struct {
+	DirReader DirReader
+	// Exclude has glob patterns of directories and files to skip. Patterns
+	// without a slash match base names, others match paths relative to the
+	// walked directory. The walked directory itself is never skipped.
+	Exclude []string
+	// FollowSymlinks makes the walk descend into symbolic links to
+	// directories.
+	FollowSymlinks bool
+	// IgnoreFiles are the names of files with ignore rules, using the syntax
+	// of .gitignore. Rules apply to the directory of the file where they are
+	// found and its subdirectories. When walking a directory inside a Git
+	// repository, the files in its parent directories also apply.
+	IgnoreFiles []string
+}
+
+// NewRecursive creates a new Recursive that excludes DefaultExclude and the
+// given patterns, and honours DefaultIgnoreFiles.
+func NewRecursive(dirReader DirReader, exclude ...string) Recursive {
+	return Recursive{
+		DirReader:   dirReader,
+		Exclude:     append(append([]string(nil), DefaultExclude...), exclude...),
+		IgnoreFiles: DefaultIgnoreFiles,
+	}
+}
+
+// ReadDir extracts documentation metadata from path and its subdirectories.
+// Paths that are not directories are passed as is to the underlying reader.
+func (r Recursive) ReadDir(path string) ([]*types.Package, error) {
+	var pkgs []*types.Package
+	err := r.ReadStream(path, func(p *types.Package) error {
+		pkgs = append(pkgs, p)
+		return nil
+	})
+	return pkgs, err
+}
+
+// ReadStream reads path and its subdirectories, calling fn for each package.
+// Readers that implement TreeReader read them all at once before the walk.
+func (r Recursive) ReadStream(path string, fn func(*types.Package) error) error {
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return readStream(r.DirReader, path, fn)
+	}
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	ignores, err := parentIgnores(root, r.IgnoreFiles)
+	if err != nil {
+		return err
+	}
+	dirReader := r.DirReader
+	if tr, ok := dirReader.(TreeReader); ok {
+		if dirReader, err = tr.ReadTree(path); err != nil {
+			return err
+		}
+	}
+	visited := make(map[string]bool)
+	return r.walk(root, root, ignores, visited, func(dir string, ignores ignoreList) error {
+		return readStream(dirReader, filepath.Join(path, strings.TrimPrefix(dir, root)), func(p *types.Package) error {
+			if p = r.excludeFiles(root, ignores, p); p != nil {
+				return fn(p)
+			}
+			return nil
+		})
+	})
+}
+
+// walk calls fn for dir and each of its subdirectories that is not excluded
+// or ignored, with the ignore rules that apply to them.
+func (r Recursive) walk(root, dir string, ignores ignoreList, visited map[string]bool, fn func(dir string, ignores ignoreList) error) error {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if visited[real] {
+			// avoid symlink cycles
+			return nil
+		}
+		visited[real] = true
+	}
+	for _, name := range r.IgnoreFiles {
+		var err error
+		if ignores, err = ignores.readIgnoreFile(dir, name); err != nil {
+			return err
+		}
+	}
+	if err := fn(dir, ignores); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Mode()&os.ModeSymlink != 0 && r.FollowSymlinks {
+			if fi, err := os.Stat(path); err == nil {
+				isDir = fi.IsDir()
+			}
+		}
+		if !isDir || r.Excluded(root, path) || ignores.ignored(path, true) {
+			continue
+		}
+		if err := r.walk(root, path, ignores, visited, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Excluded returns true if path, under root, matches any exclude pattern.
+func (r Recursive) Excluded(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range r.Exclude {
+		name := filepath.Base(rel)
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeFiles removes from p the documentation of excluded and ignored files.
+// It returns nil if nothing is left of a package that had documentation.
+func (r Recursive) excludeFiles(root string, ignores ignoreList, p *types.Package) *types.Package {
+	if len(p.Documentation) == 0 {
+		return p
+	}
+	docs := p.Documentation[:0]
+	for _, text := range p.Documentation {
+		filename, err := filepath.Abs(text.Position.Filename)
+		if err != nil || !r.Excluded(root, filename) && !ignores.ignored(filename, false) {
+			docs = append(docs, text)
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	p.Documentation = docs
+	return p
+}
+
+// readStream reads the documentation in path using dirReader, calling fn for
+// each package.
+func readStream(dirReader DirReader, path string, fn func(*types.Package) error) error {
+	if sr, ok := dirReader.(StreamReader); ok {
+		return sr.ReadStream(path, fn)
+	}
+	pkgs, err := dirReader.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if err := fn(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package read
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRecursive(t *testing.T) {
+	root := "testdata/walk"
+	for _, tt := range []struct {
+		exclude        []string
+		followSymlinks bool
+		want           []string
+	}{
+		{
+			want: []string{"README", "docs/draft.txt", "docs/guide.txt"},
+		},
+		{
+			exclude: []string{"draft.*"},
+			want:    []string{"README", "docs/guide.txt"},
+		},
+		{
+			exclude: []string{"docs"},
+			want:    []string{"README"},
+		},
+		{
+			exclude: []string{"docs/guide.txt"},
+			want:    []string{"README", "docs/draft.txt"},
+		},
+		{
+			followSymlinks: true,
+			want:           []string{"README", "docs/draft.txt", "docs/guide.txt", "link/README", "link/notes.txt"},
+		},
+	} {
+		r := NewRecursive(TextFormat{}, tt.exclude...)
+		r.FollowSymlinks = tt.followSymlinks
+		pkgs, err := r.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pkg := range pkgs {
+			rel, err := filepath.Rel(root, pkg.Documentation[0].Position.Filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if len(got) != len(tt.want) || !haveSameElements(got, tt.want) {
+			t.Errorf("%+v.ReadDir(%q) read %q, want %q", r, root, got, tt.want)
+		}
+	}
+}
+
+func TestRecursiveFile(t *testing.T) {
+	path := "testdata/wikipedia/dump.xml"
+	pkgs, err := NewRecursive(WikipediaFormat{}).ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := WikipediaFormat{}.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != len(want) {
+		t.Errorf("ReadDir(%q) got %d packages, want %d", path, len(pkgs), len(want))
+	}
+}
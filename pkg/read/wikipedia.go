@@ -0,0 +1,301 @@
+package read
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// WikipediaFormat can read articles from MediaWiki XML dumps, like the
+// Wikipedia database dumps, optionally compressed with bzip2.
+type WikipediaFormat struct{}
+
+// ReadDir extracts the prose of all articles in the dump in path, or in STDIN
+// if path is "-". Prefer ReadStream for large dumps.
+func (f WikipediaFormat) ReadDir(path string) ([]*types.Package, error) {
+	var r []*types.Package
+	err := f.ReadStream(path, func(p *types.Package) error {
+		r = append(r, p)
+		return nil
+	})
+	return r, err
+}
+
+// ReadStream extracts the prose of the articles in the dump in path, or in
+// STDIN if path is "-", calling fn for each article as soon as it is read.
+func (f WikipediaFormat) ReadStream(path string, fn func(*types.Package) error) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	return f.ReadDump(path, r, fn)
+}
+
+// wikiPage is a page of a MediaWiki XML export.
+type wikiPage struct {
+	Title    string    `xml:"title"`
+	NS       int       `xml:"ns"`
+	Redirect *struct{} `xml:"redirect"`
+	Revision struct {
+		Text string `xml:"text"`
+	} `xml:"revision"`
+}
+
+// ReadDump extracts the prose of the articles in a dump read from r, calling
+// fn for each article. The dump is decompressed if it starts with a bzip2
+// header. Only one page is held in memory at a time. Redirects and pages
+// outside the main namespace are skipped.
+//
+// Each article becomes a package named after its title. The position of each
+// paragraph has the filename name#Title, and offset, line and column relative
+// to the revision text. Such positions are not in a file of their own, so
+// misspellings in dumps can be reported, but not fixed.
+func (f WikipediaFormat) ReadDump(name string, r io.Reader, fn func(*types.Package) error) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(3); err == nil && string(magic) == "BZh" {
+		r = bzip2.NewReader(br)
+	} else {
+		r = br
+	}
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+		var page wikiPage
+		if err := dec.DecodeElement(&page, &start); err != nil {
+			return err
+		}
+		if page.NS != 0 || page.Redirect != nil {
+			continue
+		}
+		if err := fn(f.ReadPage(name, page.Title, page.Revision.Text)); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadPage extracts the paragraphs of prose of an article, with templates,
+// references, tables, comments, link targets and formatting removed. Removed
+// markup is replaced with spaces, so that offsets match the revision text,
+// save for the labels of links followed by letters, as in [[rodent]]s, which
+// are moved next to them.
+func (f WikipediaFormat) ReadPage(name, title, text string) *types.Package {
+	p := &types.Package{Name: title}
+	masked := maskWikitext([]byte(text))
+	for _, paragraph := range textParagraphs(masked) {
+		pos := offsetPosition(name+"#"+title, masked, paragraph.begin)
+		p.Documentation = append(p.Documentation, &types.Text{
+			Content:  string(masked[paragraph.begin:paragraph.end]),
+			Position: pos,
+		})
+	}
+	return p
+}
+
+// wikiCodeTags are the tags whose content is not prose.
+const wikiCodeTags = "math|code|pre|nowiki|source|syntaxhighlight|gallery|timeline|score|chem"
+
+var (
+	wikiComment     = regexp.MustCompile(`(?s)<!--.*?(?:-->|$)`)
+	wikiRef         = regexp.MustCompile(`(?is)<ref(?:\s[^>]*)?/>|<ref(?:\s[^>]*)?>.*?</ref\s*>`)
+	wikiCodeElement = regexp.MustCompile(`(?is)<(?:` + wikiCodeTags + `)(?:\s[^>]*)?>.*?</(?:` + wikiCodeTags + `)\s*>`)
+	wikiTag         = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9]*(?:\s[^<>]*)?/?>`)
+	wikiMagicWord   = regexp.MustCompile(`__[A-Z]+__`)
+	wikiExtLink     = regexp.MustCompile(`\[(?:https?:|ftp:|mailto:|//)[^\s\]]*\s?|\]`)
+	wikiFormatting  = regexp.MustCompile(`'{2,}`)
+	wikiNamespace   = regexp.MustCompile(`^\s*:?\s*(?i:file|image|category|media|[a-z]{2,3}(?:-[a-z]+)?)\s*:`)
+)
+
+// maskWikitext replaces everything that is not prose in wikitext with spaces,
+// preserving line breaks.
+func maskWikitext(text []byte) []byte {
+	b := append([]byte(nil), text...)
+	mask := func(begin, end int) {
+		for i := begin; i < end; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	maskAll := func(re *regexp.Regexp) {
+		for _, m := range re.FindAllIndex(b, -1) {
+			mask(m[0], m[1])
+		}
+	}
+	maskAll(wikiComment)
+	maskAll(wikiRef)
+	maskAll(wikiCodeElement)
+	maskNested(b, "{{", "}}", mask)
+	maskWikiTables(b, mask)
+	maskWikiLinks(b, mask)
+	maskAll(wikiTag)
+	maskAll(wikiMagicWord)
+	maskAll(wikiExtLink)
+	maskAll(wikiFormatting)
+	return b
+}
+
+// maskNested masks every outermost region of b delimited by open and close,
+// which may be nested.
+func maskNested(b []byte, open, close string, mask func(begin, end int)) {
+	depth, begin := 0, 0
+	for i := 0; i+1 < len(b); i++ {
+		switch {
+		case bytes.HasPrefix(b[i:], []byte(open)):
+			if depth == 0 {
+				begin = i
+			}
+			depth++
+			i++
+		case depth > 0 && bytes.HasPrefix(b[i:], []byte(close)):
+			depth--
+			i++
+			if depth == 0 {
+				mask(begin, i+1)
+			}
+		}
+	}
+	if depth > 0 {
+		mask(begin, len(b))
+	}
+}
+
+// maskWikiTables masks the markup of tables, keeping the text of cells and
+// captions.
+func maskWikiTables(b []byte, mask func(begin, end int)) {
+	depth := 0
+	for begin := 0; begin < len(b); {
+		end := len(b)
+		if i := bytes.IndexByte(b[begin:], '\n'); i >= 0 {
+			end = begin + i
+		}
+		line := bytes.TrimLeft(b[begin:end], " \t")
+		lineBegin := end - len(line)
+		switch {
+		case bytes.HasPrefix(line, []byte("{|")):
+			depth++
+			mask(lineBegin, end)
+		case depth > 0 && bytes.HasPrefix(line, []byte("|}")):
+			depth--
+			mask(lineBegin, end)
+		case depth > 0 && bytes.HasPrefix(line, []byte("|-")):
+			mask(lineBegin, end)
+		case depth > 0 && (bytes.HasPrefix(line, []byte("|")) || bytes.HasPrefix(line, []byte("!"))):
+			maskWikiCells(b, lineBegin, end, mask)
+		}
+		begin = end + 1
+	}
+}
+
+// maskWikiCells masks the markup of a table row in b[begin:end]: cell
+// separators and cell attributes.
+func maskWikiCells(b []byte, begin, end int, mask func(begin, end int)) {
+	sep := []byte("||")
+	if b[begin] == '!' {
+		sep = []byte("!!")
+	}
+	mask(begin, begin+1)
+	if bytes.HasPrefix(b[begin:end], []byte("|+")) {
+		// table caption
+		mask(begin, begin+2)
+	}
+	for cell := begin + 1; cell < end; {
+		cellEnd := end
+		if i := bytes.Index(b[cell:end], sep); i >= 0 {
+			cellEnd = cell + i
+		}
+		// attributes come before a single pipe, outside links
+		depth := 0
+		for i := cell; i < cellEnd; i++ {
+			switch {
+			case bytes.HasPrefix(b[i:cellEnd], []byte("[[")):
+				depth++
+				i++
+			case bytes.HasPrefix(b[i:cellEnd], []byte("]]")):
+				depth--
+				i++
+			case b[i] == '|' && depth == 0:
+				mask(cell, i+1)
+				i = cellEnd
+			}
+		}
+		if cellEnd < end {
+			mask(cellEnd, cellEnd+len(sep))
+		}
+		cell = cellEnd + len(sep)
+	}
+}
+
+// maskWikiLinks masks internal links, keeping their labels. Labels followed
+// by a link trail are moved two bytes forward, to end where the trail begins.
+// Links to files, categories and other languages are masked entirely.
+func maskWikiLinks(b []byte, mask func(begin, end int)) {
+	for i := 0; i+1 < len(b); i++ {
+		if !bytes.HasPrefix(b[i:], []byte("[[")) {
+			continue
+		}
+		// find the matching ]]
+		depth, end := 0, -1
+		for j := i; j+1 < len(b); j++ {
+			if bytes.HasPrefix(b[j:], []byte("[[")) {
+				depth++
+				j++
+			} else if bytes.HasPrefix(b[j:], []byte("]]")) {
+				depth--
+				j++
+				if depth == 0 {
+					end = j + 1
+					break
+				}
+			}
+		}
+		if end < 0 {
+			mask(i, i+2)
+			continue
+		}
+		inner := b[i+2 : end-2]
+		if wikiNamespace.Match(inner) {
+			mask(i, end)
+			i = end - 1
+			continue
+		}
+		mask(i, i+2)
+		mask(end-2, end)
+		label := i + 2
+		if pipe := bytes.IndexByte(inner, '|'); pipe >= 0 && !strings.Contains(string(inner[:pipe]), "[[") {
+			mask(i+2, i+2+pipe+1)
+			label += pipe + 1
+		}
+		// letters right after a link, as in [[rodent]]s, are part of its
+		// last word: move the label over the closing brackets, next to them
+		if trail, _ := utf8.DecodeRune(b[end:]); unicode.IsLetter(trail) && bytes.IndexAny(b[label:end-2], "[\n") < 0 {
+			copy(b[label+2:end], b[label:end-2])
+			mask(label, label+2)
+		}
+		// nested links in labels are handled in the next iterations
+		i++
+	}
+}
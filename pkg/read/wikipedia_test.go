@@ -0,0 +1,52 @@
+package read
+
+import "testing"
+
+func TestReadDirWikipedia(t *testing.T) {
+	for _, path := range []string{"testdata/wikipedia/dump.xml", "testdata/wikipedia/dump.xml.bz2"} {
+		pkgs, err := WikipediaFormat{}.ReadDir(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(pkgs), 1; got != want {
+			t.Fatalf("ReadDir(%q) got %d packages, want %d", path, got, want)
+		}
+		pkg := pkgs[0]
+		if got, want := pkg.Name, "Gopher"; got != want {
+			t.Errorf("pkg.Name = %q, want %q", got, want)
+		}
+		for i, want := range []struct {
+			content              string
+			offset, line, column int
+		}{
+			{"Gophers    are     rodents of the             family Geomyidae  .                                    They are cute.", 69, 5, 4},
+			{"== Habitat ==\nGophers live in                     burows  underground.                       ", 186, 7, 1},
+			{"Name    Size", 307, 12, 3},
+			{"Pocket gopher    small", 345, 14, 23},
+		} {
+			if i >= len(pkg.Documentation) {
+				t.Errorf("missing pkg.Documentation[%d], want %q", i, want.content)
+				continue
+			}
+			text := pkg.Documentation[i]
+			if text.Content != want.content {
+				t.Errorf("pkg.Documentation[%d].Content = %q, want %q", i, text.Content, want.content)
+			}
+			if got, want := text.Position.Filename, path+"#Gopher"; got != want {
+				t.Errorf("pkg.Documentation[%d].Filename = %q, want %q", i, got, want)
+			}
+			if got := text.Position.Offset; got != want.offset {
+				t.Errorf("pkg.Documentation[%d].Offset = %d, want %d", i, got, want.offset)
+			}
+			if got := text.Position.Line; got != want.line {
+				t.Errorf("pkg.Documentation[%d].Line = %d, want %d", i, got, want.line)
+			}
+			if got := text.Position.Column; got != want.column {
+				t.Errorf("pkg.Documentation[%d].Column = %d, want %d", i, got, want.column)
+			}
+		}
+		if got, want := len(pkg.Documentation), 4; got != want {
+			t.Errorf("len(pkg.Documentation) = %d, want %d", got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A unit is a type-checked package: a package of the module, the package
+// with its _test.go files, or an external test package.
+type unit struct {
+	pkg   *types.Package
+	info  *types.Info
+	files []*ast.File
+}
+
+// A module is a Go module loaded from source.
+type module struct {
+	fset  *token.FileSet
+	units []*unit
+}
+
+// loadModule type-checks all packages of the module that contains dir,
+// including their tests. Files are selected as the go command does, with the
+// build tags and the GOFLAGS environment variable.
+func loadModule(dir string, tags []string) (*module, error) {
+	root, err := findModuleRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	conf := &packages.Config{
+		// packages of the module import each other as type-checked from
+		// source, so that their objects are shared by all units
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Dir:   root,
+		Fset:  fset,
+		Tests: true,
+	}
+	if len(tags) > 0 {
+		conf.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	loaded, err := packages.Load(conf, "./...")
+	if err != nil {
+		return nil, err
+	}
+	mod := &module{fset: fset}
+	for _, lp := range loaded {
+		if strings.HasSuffix(lp.ID, ".test") || len(lp.GoFiles) == 0 {
+			// test binaries, and packages whose files are all
+			// excluded by build constraints
+			continue
+		}
+		if len(lp.Errors) > 0 {
+			return nil, fmt.Errorf("cannot rename with type errors: %v", lp.Errors[0])
+		}
+		mod.units = append(mod.units, &unit{pkg: lp.Types, info: lp.TypesInfo, files: lp.Syntax})
+	}
+	return mod, nil
+}
+
+// findModuleRoot returns the closest directory to dir, or dir itself, that
+// contains a go.mod file.
+func findModuleRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d, nil
+		}
+		if d == filepath.Dir(d) {
+			return "", fmt.Errorf("%s: not in a Go module", dir)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Package rename renames Go identifiers and all references to them, using
+// type information to find the references and to refuse unsafe renames.
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An Edit replaces Old with New at Offset in a file.
+type Edit struct {
+	Filename string
+	Offset   int
+	Old, New string
+}
+
+// ErrExported is returned by Rename when the rename changes the exported API
+// of a package and that was not allowed.
+var ErrExported = errors.New("renaming changes the exported API, confirmation required")
+
+// A ConflictError is returned by Rename when the new name would conflict with
+// other names.
+type ConflictError struct {
+	Pos    token.Position // position of the conflict
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Reason)
+}
+
+// Rename returns the edits that rename the identifier at pos, which may be the
+// declaration or a reference, to newName, and every reference to it in all the
+// packages and tests of the Go module that contains the file, selected with the
+// build tags. Renames that change the exported API of a package are only done
+// if allowExported is true.
+//
+// Rename returns an error if the module has type errors or if the new name
+// would conflict with other names, shadow them or be shadowed, or break the
+// implementation of interfaces.
+func Rename(pos token.Position, newName string, allowExported bool, tags []string) ([]Edit, error) {
+	if !token.IsIdentifier(newName) {
+		return nil, fmt.Errorf("%q is not a valid identifier", newName)
+	}
+	filename, err := filepath.Abs(pos.Filename)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := loadModule(filepath.Dir(filename), tags)
+	if err != nil {
+		return nil, err
+	}
+	r := &renamer{mod: mod, newName: newName}
+	if err := r.findTarget(filename, pos.Offset); err != nil {
+		return nil, err
+	}
+	r.findReferences()
+	if err := r.checkConflicts(); err != nil {
+		return nil, err
+	}
+	if err := r.checkExported(allowExported); err != nil {
+		return nil, err
+	}
+	return r.edits(), nil
+}
+
+// A renamer holds the state of a rename.
+type renamer struct {
+	mod     *module
+	newName string
+	// the renamed object
+	obj types.Object
+	// key of the renamed object, the same in every unit
+	key token.Position
+	// identifiers that refer to the renamed object, by unit
+	refs map[*unit][]*ast.Ident
+}
+
+// objectKey identifies an object across units by the position of its
+// declaration.
+func (r *renamer) objectKey(obj types.Object) token.Position {
+	pos := r.mod.fset.Position(obj.Pos())
+	return token.Position{Filename: pos.Filename, Offset: pos.Offset}
+}
+
+// findTarget finds the object of the identifier at offset in filename.
+func (r *renamer) findTarget(filename string, offset int) error {
+	for _, u := range r.mod.units {
+		for _, f := range u.files {
+			if r.mod.fset.Position(f.Pos()).Filename != filename {
+				continue
+			}
+			var obj types.Object
+			ast.Inspect(f, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok || obj != nil {
+					return obj == nil
+				}
+				p := r.mod.fset.Position(ident.Pos())
+				if p.Offset <= offset && offset < p.Offset+len(ident.Name) {
+					obj = u.info.Defs[ident]
+					if obj == nil {
+						obj = u.info.Uses[ident]
+					}
+				}
+				return true
+			})
+			if obj == nil {
+				continue
+			}
+			if obj.Pkg() == nil || obj.Name() == "_" {
+				return fmt.Errorf("cannot rename %s", obj.Name())
+			}
+			if _, ok := obj.(*types.PkgName); ok {
+				return fmt.Errorf("cannot rename import %s", obj.Name())
+			}
+			if v, ok := obj.(*types.Var); ok && v.Embedded() {
+				return fmt.Errorf("cannot rename embedded field %s, rename its type", obj.Name())
+			}
+			r.obj, r.key = obj, r.objectKey(obj)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s:#%d: no identifier found", filename, offset)
+}
+
+// findReferences finds the identifiers that refer to the renamed object.
+func (r *renamer) findReferences() {
+	r.refs = make(map[*unit][]*ast.Ident)
+	for _, u := range r.mod.units {
+		for _, m := range []map[*ast.Ident]types.Object{u.info.Defs, u.info.Uses} {
+			for ident, obj := range m {
+				if obj != nil && r.objectKey(obj) == r.key {
+					r.refs[u] = append(r.refs[u], ident)
+				}
+			}
+		}
+		// type switch variables have no object of their own
+		for node, obj := range u.info.Implicits {
+			if _, ok := node.(*ast.CaseClause); ok && r.objectKey(obj) == r.key {
+				r.refs[u] = append(r.refs[u], r.declIdent(u))
+				break
+			}
+		}
+	}
+}
+
+// declIdent returns the identifier at the position of the declaration of the
+// renamed object in u.
+func (r *renamer) declIdent(u *unit) *ast.Ident {
+	var found *ast.Ident
+	for _, f := range u.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && found == nil {
+				p := r.mod.fset.Position(ident.Pos())
+				if p.Filename == r.key.Filename && p.Offset == r.key.Offset {
+					found = ident
+				}
+			}
+			return found == nil
+		})
+	}
+	return found
+}
+
+// isLocal returns true if the renamed object is declared inside a function.
+func (r *renamer) isLocal() bool {
+	parent := r.obj.Parent()
+	return parent != nil && parent != r.obj.Pkg().Scope()
+}
+
+// checkExported returns an error if the rename changes the exported API and
+// that is not allowed, or if the new name is unexported and the object is
+// used by other packages.
+func (r *renamer) checkExported(allowExported bool) error {
+	if r.isLocal() {
+		return nil
+	}
+	if !token.IsExported(r.newName) {
+		for u, idents := range r.refs {
+			if strings.TrimSuffix(u.pkg.Path(), "_test") != r.obj.Pkg().Path() {
+				return &ConflictError{
+					Pos:    r.mod.fset.Position(idents[0].Pos()),
+					Reason: fmt.Sprintf("%s is used by package %s, it cannot be unexported", r.obj.Name(), u.pkg.Path()),
+				}
+			}
+		}
+	}
+	if (r.obj.Exported() || token.IsExported(r.newName)) && !allowExported {
+		return ErrExported
+	}
+	return nil
+}
+
+// checkConflicts returns an error if the new name conflicts with other names.
+func (r *renamer) checkConflicts() error {
+	for _, u := range r.mod.units {
+		obj := r.unitObject(u)
+		if obj == nil {
+			continue
+		}
+		var err error
+		if obj.Parent() == nil {
+			err = r.checkFieldOrMethod(u, obj)
+		} else if u.pkg.Path() == obj.Pkg().Path() {
+			err = r.checkScopes(u, obj)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conflict returns a ConflictError at pos.
+func (r *renamer) conflict(pos token.Pos, format string, args ...interface{}) error {
+	return &ConflictError{Pos: r.mod.fset.Position(pos), Reason: fmt.Sprintf(format, args...)}
+}
+
+// checkScopes returns an error if renaming the package-level or local object
+// obj, in the package of unit u, conflicts with other names in scope.
+func (r *renamer) checkScopes(u *unit, obj types.Object) error {
+	scope := obj.Parent()
+	if other := scope.Lookup(r.newName); other != nil {
+		return r.conflict(other.Pos(), "%s is already declared", r.newName)
+	}
+	pkgLevel := scope == obj.Pkg().Scope()
+	if pkgLevel {
+		for _, f := range u.files {
+			if other := u.info.Scopes[f].Lookup(r.newName); other != nil {
+				return r.conflict(other.Pos(), "%s is already declared in this file", r.newName)
+			}
+		}
+	}
+	// the renamed object must not be shadowed at its references
+	for _, ident := range r.refs[u] {
+		inner := u.pkg.Scope().Innermost(ident.Pos())
+		if inner == nil {
+			continue
+		}
+		if _, other := inner.LookupParent(r.newName, ident.Pos()); other != nil && other.Parent() != scope && isAncestor(scope, other.Parent()) {
+			return r.conflict(ident.Pos(), "%s would be shadowed by %s declared at %s", obj.Name(), r.newName, r.mod.fset.Position(other.Pos()))
+		}
+	}
+	// objects declared outside of the scope of the renamed object must not be
+	// shadowed by it
+	for ident, other := range u.info.Uses {
+		if ident.Name != r.newName || other.Parent() == nil || !isAncestor(other.Parent(), scope) {
+			continue
+		}
+		if pkgLevel || scope.Contains(ident.Pos()) && ident.Pos() > obj.Pos() {
+			return r.conflict(ident.Pos(), "%s would shadow %s declared at %s", obj.Name(), r.newName, r.mod.fset.Position(other.Pos()))
+		}
+	}
+	return nil
+}
+
+// isAncestor returns true if outer is inner or one of its parents.
+func isAncestor(outer, inner *types.Scope) bool {
+	for s := inner; s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// unitObject returns the renamed object as seen in unit u.
+func (r *renamer) unitObject(u *unit) types.Object {
+	if ident := r.declIdent(u); ident != nil {
+		if obj := u.info.Defs[ident]; obj != nil {
+			return obj
+		}
+	}
+	for _, ident := range r.refs[u] {
+		if obj := u.info.Uses[ident]; obj != nil {
+			return obj
+		}
+	}
+	return nil
+}
+
+// checkFieldOrMethod returns an error if renaming the field or method obj
+// conflicts with another field or method, or breaks the implementation of an
+// interface.
+func (r *renamer) checkFieldOrMethod(u *unit, obj types.Object) error {
+	// the types that have obj as a field or method
+	var owners []types.Type
+	if fn, ok := obj.(*types.Func); ok {
+		recv := fn.Type().(*types.Signature).Recv()
+		if recv != nil {
+			owners = append(owners, recv.Type())
+		}
+	}
+	for _, tv := range u.info.Types {
+		if st, ok := tv.Type.(*types.Struct); ok && hasField(st, obj) {
+			owners = append(owners, st)
+		}
+	}
+	for _, def := range u.info.Defs {
+		if tn, ok := def.(*types.TypeName); ok {
+			if st, ok := tn.Type().Underlying().(*types.Struct); ok && hasField(st, obj) {
+				owners = append(owners, types.NewPointer(tn.Type()))
+			}
+		}
+	}
+	for _, owner := range owners {
+		if other, _, _ := types.LookupFieldOrMethod(owner, true, obj.Pkg(), r.newName); other != nil {
+			return r.conflict(other.Pos(), "%s is already a field or method of %s", r.newName, owner)
+		}
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	// renaming an interface method or a method that implements an interface
+	// changes which types implement it
+	for _, def := range u.info.Defs {
+		tn, ok := def.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		iface, isIface := tn.Type().Underlying().(*types.Interface)
+		if types.IsInterface(recv.Type()) {
+			if isIface || tn.IsAlias() {
+				continue
+			}
+			recvIface := recv.Type().Underlying().(*types.Interface)
+			if types.Implements(tn.Type(), recvIface) || types.Implements(types.NewPointer(tn.Type()), recvIface) {
+				return r.conflict(tn.Pos(), "%s implements the interface of %s", tn.Name(), obj.Name())
+			}
+			continue
+		}
+		if !isIface || iface.NumMethods() == 0 {
+			continue
+		}
+		if m, _, _ := types.LookupFieldOrMethod(iface, false, obj.Pkg(), obj.Name()); m == nil {
+			continue
+		}
+		if types.Implements(recv.Type(), iface) {
+			return r.conflict(tn.Pos(), "%s would no longer implement %s", recv.Type(), tn.Name())
+		}
+	}
+	return nil
+}
+
+// hasField returns true if st has a field declared at the same position as
+// obj.
+func hasField(st *types.Struct, obj types.Object) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Pos() == obj.Pos() {
+			return true
+		}
+	}
+	return false
+}
+
+// edits returns the edits of all references, sorted by file and offset.
+func (r *renamer) edits() []Edit {
+	seen := make(map[token.Position]bool)
+	var edits []Edit
+	for _, idents := range r.refs {
+		for _, ident := range idents {
+			if ident == nil {
+				continue
+			}
+			pos := r.mod.fset.Position(ident.Pos())
+			key := token.Position{Filename: pos.Filename, Offset: pos.Offset}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			edits = append(edits, Edit{Filename: pos.Filename, Offset: pos.Offset, Old: ident.Name, New: r.newName})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Filename != edits[j].Filename {
+			return edits[i].Filename < edits[j].Filename
+		}
+		return edits[i].Offset < edits[j].Offset
+	})
+	return edits
+}
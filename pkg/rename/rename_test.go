@@ -0,0 +1,192 @@
+package rename
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testModule = map[string]string{
+	"go.mod": "module example.com/m\n",
+	"a/a.go": `package a
+
+// RecieveBuffer is misspelled.
+type RecieveBuffer struct {
+	Data []byte
+	size int
+}
+
+func (b *RecieveBuffer) Len() int { return b.size }
+
+func NewRecieveBuffer() *RecieveBuffer { return &RecieveBuffer{} }
+
+type Lener interface{ Len() int }
+
+func count(lenght int) int {
+	total := 0
+	for i := 0; i < lenght; i++ {
+		total += i
+	}
+	return total
+}
+`,
+	"a/extra.go": `//go:build extra
+
+package a
+
+var extra RecieveBuffer
+`,
+	"a/a_test.go": `package a
+
+var _ = RecieveBuffer{size: 1}
+`,
+	"b/b.go": `package b
+
+import "example.com/m/a"
+
+var B a.RecieveBuffer
+
+func F() int { return len(a.NewRecieveBuffer().Data) }
+`,
+}
+
+// writeModule writes the test module to a temporary directory.
+func writeModule(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "typokiller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testModule {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// position returns the position of the first occurrence of s in the file
+// name of the module in dir.
+func position(dir, name, s string) token.Position {
+	return token.Position{
+		Filename: filepath.Join(dir, filepath.FromSlash(name)),
+		Offset:   strings.Index(testModule[name], s),
+	}
+}
+
+func TestRename(t *testing.T) {
+	dir := writeModule(t)
+	defer os.RemoveAll(dir)
+
+	edits, err := Rename(position(dir, "b/b.go", "RecieveBuffer"), "ReceiveBuffer", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, edit := range edits {
+		rel, _ := filepath.Rel(dir, edit.Filename)
+		got[filepath.ToSlash(rel)]++
+		if edit.Old != "RecieveBuffer" || edit.New != "ReceiveBuffer" {
+			t.Errorf("edit = %+v, want RecieveBuffer replaced with ReceiveBuffer", edit)
+		}
+		b, err := ioutil.ReadFile(edit.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := string(b[edit.Offset : edit.Offset+len(edit.Old)]); found != edit.Old {
+			t.Errorf("edit at %s:#%d has %q in the source, want %q", rel, edit.Offset, found, edit.Old)
+		}
+	}
+	want := map[string]int{"a/a.go": 4, "a/a_test.go": 1, "b/b.go": 1}
+	if len(got) != len(want) {
+		t.Errorf("edits by file = %v, want %v", got, want)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("edits by file = %v, want %v", got, want)
+			break
+		}
+	}
+
+	edits, err = Rename(position(dir, "a/a.go", "lenght int"), "length", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 2 {
+		t.Errorf("renaming lenght got %d edits, want 2", len(edits))
+	}
+}
+
+func TestRenameTags(t *testing.T) {
+	dir := writeModule(t)
+	defer os.RemoveAll(dir)
+
+	extra := filepath.Join(dir, "a", "extra.go")
+	for _, tt := range []struct {
+		tags []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"extra"}, 1},
+	} {
+		edits, err := Rename(position(dir, "a/a.go", "RecieveBuffer struct"), "ReceiveBuffer", true, tt.tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := 0
+		for _, edit := range edits {
+			if edit.Filename == extra {
+				got++
+			}
+		}
+		if got != tt.want {
+			t.Errorf("renaming with tags %q got %d edits in extra.go, want %d", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestRenameRefused(t *testing.T) {
+	dir := writeModule(t)
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name, s, newName string
+		conflict         bool
+	}{
+		// exported API changes must be allowed
+		{"a/a.go", "RecieveBuffer struct", "ReceiveBuffer", false},
+		// used by package b
+		{"a/a.go", "RecieveBuffer struct", "receiveBuffer", true},
+		// already declared in the same scope
+		{"a/a.go", "lenght int", "total", true},
+		// shadowed by the loop variable at a reference
+		{"a/a.go", "total := 0", "i", true},
+		// would shadow the parameter in the loop
+		{"a/a.go", "i := 0", "lenght", true},
+		// would no longer implement Lener
+		{"a/a.go", "Len() int { return", "Size", true},
+		// already a field
+		{"a/a.go", "size int", "Data", true},
+		// not an identifier
+		{"a/a.go", "lenght int", "len-ght", false},
+	} {
+		_, err := Rename(position(dir, tt.name, tt.s), tt.newName, strings.HasPrefix(tt.newName, "receive"), nil)
+		if err == nil {
+			t.Errorf("Rename(%q in %s, %q) returned err=nil, want error", tt.s, tt.name, tt.newName)
+			continue
+		}
+		if _, ok := err.(*ConflictError); ok != tt.conflict {
+			t.Errorf("Rename(%q in %s, %q) returned err=%v, want ConflictError: %v", tt.s, tt.name, tt.newName, err, tt.conflict)
+		}
+	}
+	_, err := Rename(position(dir, "a/a.go", "RecieveBuffer struct"), "ReceiveBuffer", false, nil)
+	if err != ErrExported {
+		t.Errorf("Rename of exported name returned err=%v, want %v", err, ErrExported)
+	}
+}
@@ -0,0 +1,50 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// Plain writes potential misspells non-interactively, one per line, in the
+// format file:line:col: word (suggestions). Misspells in identifiers are
+// followed by the identifier and their severity, as in
+// file:line:col: word in Identifier (suggestions) [major].
+type Plain struct {
+	w     io.Writer
+	count int
+}
+
+// NewPlain creates a new Plain reporter that writes to w.
+func NewPlain(w io.Writer) *Plain {
+	return &Plain{w: w}
+}
+
+// Report writes the misspellings of text.
+func (p *Plain) Report(text *types.Text) error {
+	for _, m := range text.Misspellings {
+		pos := m.Position()
+		line := fmt.Sprintf("%s:%d:%d: %s", pos.Filename, pos.Line, pos.Column, m.Word)
+		if text.Identifier {
+			line += " in " + text.Content
+		}
+		if len(m.Suggestions) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(m.Suggestions, ", "))
+		}
+		if text.Identifier {
+			line += fmt.Sprintf(" [%s]", m.Severity)
+		}
+		if _, err := fmt.Fprintln(p.w, line); err != nil {
+			return err
+		}
+		p.count++
+	}
+	return nil
+}
+
+// Count returns the number of misspellings reported so far.
+func (p *Plain) Count() int {
+	return p.count
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestPlain(t *testing.T) {
+	text := &types.Text{
+		Content:  "/*\n\tHello says helo to a Gophr.\n*/",
+		Position: token.Position{Filename: "hello.go", Offset: 55, Line: 3, Column: 1},
+	}
+	text.Misspellings = []*types.Misspelling{
+		{Word: "helo", Offset: 15, Suggestions: []string{"hello", "help"}, Text: text},
+		{Word: "Gophr", Offset: 25, Text: text},
+	}
+	var buf bytes.Buffer
+	p := NewPlain(&buf)
+	if err := p.Report(text); err != nil {
+		t.Fatal(err)
+	}
+	want := "hello.go:4:13: helo (hello, help)\nhello.go:4:23: Gophr\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Report() wrote %q, want %q", got, want)
+	}
+	if got, want := p.Count(), 2; got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+}
+
+func TestPlainIdentifier(t *testing.T) {
+	text := &types.Text{
+		Content:    "RecieveBuffer",
+		Position:   token.Position{Filename: "buffer.go", Offset: 60, Line: 5, Column: 6},
+		Identifier: true,
+		Exported:   true,
+	}
+	text.Misspellings = []*types.Misspelling{
+		{Word: "Recieve", Offset: 0, Suggestions: []string{"Receive"}, Severity: types.Major, Text: text},
+	}
+	var buf bytes.Buffer
+	if err := NewPlain(&buf).Report(text); err != nil {
+		t.Fatal(err)
+	}
+	want := "buffer.go:5:6: Recieve in RecieveBuffer (Receive) [major]\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Report() wrote %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+package spell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// flagType is the encoding of flags in Hunspell dictionaries, as defined by
+// the FLAG directive.
+type flagType int
+
+const (
+	singleFlag flagType = iota // one character (byte or UTF-8 rune) per flag
+	longFlag                   // two characters per flag
+	numFlag                    // comma-separated decimal numbers
+)
+
+// parseFlags splits s into individual flags.
+func (t flagType) parseFlags(s string) ([]string, error) {
+	var r []string
+	switch t {
+	case longFlag:
+		runes := []rune(s)
+		if len(runes)%2 != 0 {
+			return nil, fmt.Errorf("odd number of characters in long flags %q", s)
+		}
+		for i := 0; i < len(runes); i += 2 {
+			r = append(r, string(runes[i:i+2]))
+		}
+	case numFlag:
+		for _, f := range strings.Split(s, ",") {
+			if _, err := strconv.Atoi(f); err != nil {
+				return nil, fmt.Errorf("invalid numeric flag %q", f)
+			}
+			r = append(r, f)
+		}
+	default:
+		for _, c := range s {
+			r = append(r, string(c))
+		}
+	}
+	return r, nil
+}
+
+// parseFlag parses a single flag.
+func (t flagType) parseFlag(s string) (string, error) {
+	flags, err := t.parseFlags(s)
+	if err != nil {
+		return "", err
+	}
+	if len(flags) != 1 {
+		return "", fmt.Errorf("want a single flag, got %q", s)
+	}
+	return flags[0], nil
+}
+
+func hasFlag(flags []string, flag string) bool {
+	if flag == "" {
+		return false
+	}
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// An affix is a prefix or suffix rule from a PFX or SFX directive.
+type affix struct {
+	flag         string
+	crossProduct bool
+	strip        string    // characters removed from the stem
+	add          string    // characters added to the stem
+	cond         []charSet // condition the stem must match
+	contFlags    []string  // continuation classes, flags of the affixed word
+}
+
+// matchPrefix returns true if the start of stem matches the affix condition.
+func (a *affix) matchPrefix(stem string) bool {
+	for _, cs := range a.cond {
+		c, size := utf8.DecodeRuneInString(stem)
+		if size == 0 || !cs.match(c) {
+			return false
+		}
+		stem = stem[size:]
+	}
+	return true
+}
+
+// matchSuffix returns true if the end of stem matches the affix condition.
+func (a *affix) matchSuffix(stem string) bool {
+	for i := len(a.cond) - 1; i >= 0; i-- {
+		c, size := utf8.DecodeLastRuneInString(stem)
+		if size == 0 || !a.cond[i].match(c) {
+			return false
+		}
+		stem = stem[:len(stem)-size]
+	}
+	return true
+}
+
+// A charSet is one element of an affix condition: a literal character, a
+// bracket expression like [aeiou] or [^aeiou], or the wildcard '.'.
+type charSet struct {
+	chars  string
+	negate bool
+	any    bool
+}
+
+func (cs charSet) match(c rune) bool {
+	if cs.any {
+		return true
+	}
+	return strings.ContainsRune(cs.chars, c) != cs.negate
+}
+
+// parseCondition parses an affix condition, a simplified regular expression.
+func parseCondition(s string) ([]charSet, error) {
+	if s == "." {
+		return nil, nil
+	}
+	var r []charSet
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			r = append(r, charSet{any: true})
+			s = s[1:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket expression in condition %q", s)
+			}
+			cs := charSet{chars: s[1:end]}
+			if strings.HasPrefix(cs.chars, "^") {
+				cs.chars, cs.negate = cs.chars[1:], true
+			}
+			r = append(r, cs)
+			s = s[end+1:]
+		default:
+			_, size := utf8.DecodeRuneInString(s)
+			r = append(r, charSet{chars: s[:size]})
+			s = s[size:]
+		}
+	}
+	return r, nil
+}
+
+// A compoundRule is a COMPOUNDRULE pattern: a sequence of flags, each
+// optionally followed by '*' (zero or more) or '?' (zero or one).
+type compoundRule []compoundRuleItem
+
+type compoundRuleItem struct {
+	flag       string
+	quantifier byte
+}
+
+// parseCompoundRule parses a compound rule pattern. With long or numeric
+// flags, each flag is written inside parentheses, like (aa)(bb)*.
+func parseCompoundRule(s string, t flagType) (compoundRule, error) {
+	var r compoundRule
+	for len(s) > 0 {
+		var flag string
+		switch {
+		case s[0] == '(':
+			end := strings.IndexByte(s, ')')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated parenthesis in compound rule %q", s)
+			}
+			flag, s = s[1:end], s[end+1:]
+		case t == singleFlag:
+			_, size := utf8.DecodeRuneInString(s)
+			flag, s = s[:size], s[size:]
+		default:
+			return nil, fmt.Errorf("flags must be in parentheses in compound rule %q", s)
+		}
+		item := compoundRuleItem{flag: flag}
+		if len(s) > 0 && (s[0] == '*' || s[0] == '?') {
+			item.quantifier, s = s[0], s[1:]
+		}
+		r = append(r, item)
+	}
+	return r, nil
+}
+
+// match returns true if the flags of a sequence of compound parts match the
+// rule. Each part may have several sets of flags, one per homonym.
+func (rule compoundRule) match(parts [][][]string) bool {
+	if len(rule) == 0 {
+		return len(parts) == 0
+	}
+	item := rule[0]
+	partHasFlag := func() bool {
+		if len(parts) == 0 {
+			return false
+		}
+		for _, flags := range parts[0] {
+			if hasFlag(flags, item.flag) {
+				return true
+			}
+		}
+		return false
+	}
+	switch item.quantifier {
+	case '*':
+		return rule[1:].match(parts) || (partHasFlag() && rule.match(parts[1:]))
+	case '?':
+		return rule[1:].match(parts) || (partHasFlag() && rule[1:].match(parts[1:]))
+	}
+	return partHasFlag() && rule[1:].match(parts[1:])
+}
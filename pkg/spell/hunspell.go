@@ -0,0 +1,689 @@
+package spell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hunspell is a Dictionary loaded from a pair of Hunspell .aff and .dic files.
+// It supports prefix and suffix rules (including cross products and
+// continuation classes), compounding through COMPOUNDFLAG and COMPOUNDRULE,
+// and REP, MAP and TRY for suggestions.
+type Hunspell struct {
+	flagType flagType
+	aliases  [][]string // flag vectors defined by AF, referenced by number
+
+	words    map[string][][]string // flags of each homonym of a word
+	prefixes map[string][]*affix   // prefix rules by the characters they add
+	suffixes map[string][]*affix   // suffix rules by the characters they add
+
+	compoundFlag   string
+	compoundMin    int
+	compoundRules  []compoundRule
+	onlyInCompound string
+	needAffix      string
+	forbiddenWord  string
+	noSuggest      string
+	keepCase       string
+
+	try  []rune
+	rep  [][2]string
+	maps [][]string
+}
+
+// LoadHunspell loads a Hunspell dictionary from the affix file affPath and
+// the dictionary file dicPath.
+func LoadHunspell(affPath, dicPath string) (*Hunspell, error) {
+	h := &Hunspell{
+		words:       make(map[string][][]string),
+		prefixes:    make(map[string][]*affix),
+		suffixes:    make(map[string][]*affix),
+		compoundMin: 3,
+	}
+	aff, err := os.Open(affPath)
+	if err != nil {
+		return nil, err
+	}
+	defer aff.Close()
+	encoding, err := h.readAff(aff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", affPath, err)
+	}
+	dic, err := os.Open(dicPath)
+	if err != nil {
+		return nil, err
+	}
+	defer dic.Close()
+	if err := h.readDic(dic, encoding); err != nil {
+		return nil, fmt.Errorf("%s: %v", dicPath, err)
+	}
+	return h, nil
+}
+
+// decoder converts lines of a dictionary file to UTF-8.
+type decoder func(string) string
+
+func decoderFor(encoding string) (decoder, error) {
+	switch strings.ToUpper(encoding) {
+	case "", "UTF-8", "UTF8":
+		return func(s string) string { return s }, nil
+	case "ISO8859-1", "ISO-8859-1", "LATIN1":
+		return func(s string) string {
+			r := make([]rune, len(s))
+			for i := 0; i < len(s); i++ {
+				r[i] = rune(s[i])
+			}
+			return string(r)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+// readAff reads the directives of an affix file, returning the character
+// encoding it declares.
+func (h *Hunspell) readAff(r io.Reader) (encoding string, err error) {
+	decode, _ := decoderFor("")
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	// the remaining number of lines of table directives like PFX and REP
+	pending := make(map[string]int)
+	var lastAffix struct {
+		flag  string
+		cross bool
+	}
+	for scanner.Scan() {
+		lineno++
+		fields := strings.Fields(decode(scanner.Text()))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if lineno == 1 {
+			// strip a UTF-8 byte order mark
+			fields[0] = strings.TrimPrefix(fields[0], "\ufeff")
+		}
+		errorf := func(format string, a ...interface{}) error {
+			return fmt.Errorf("line %d: %s", lineno, fmt.Sprintf(format, a...))
+		}
+		directive, args := fields[0], fields[1:]
+		if len(args) == 0 {
+			continue
+		}
+		var flagErr error
+		parseFlag := func(s string) string {
+			f, err := h.flagType.parseFlag(s)
+			if err != nil && flagErr == nil {
+				flagErr = err
+			}
+			return f
+		}
+		switch directive {
+		case "SET":
+			encoding = args[0]
+			if decode, err = decoderFor(encoding); err != nil {
+				return "", errorf("%v", err)
+			}
+		case "FLAG":
+			switch args[0] {
+			case "long":
+				h.flagType = longFlag
+			case "num":
+				h.flagType = numFlag
+			case "UTF-8":
+				h.flagType = singleFlag
+			default:
+				return "", errorf("unknown flag type %q", args[0])
+			}
+		case "TRY":
+			h.try = []rune(args[0])
+		case "COMPOUNDFLAG":
+			h.compoundFlag = parseFlag(args[0])
+		case "ONLYINCOMPOUND":
+			h.onlyInCompound = parseFlag(args[0])
+		case "NEEDAFFIX", "PSEUDOROOT":
+			h.needAffix = parseFlag(args[0])
+		case "FORBIDDENWORD":
+			h.forbiddenWord = parseFlag(args[0])
+		case "NOSUGGEST":
+			h.noSuggest = parseFlag(args[0])
+		case "KEEPCASE":
+			h.keepCase = parseFlag(args[0])
+		case "COMPOUNDMIN":
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return "", errorf("invalid COMPOUNDMIN: %v", err)
+			}
+			if n < 1 {
+				n = 1
+			}
+			h.compoundMin = n
+		case "AF", "REP", "MAP", "COMPOUNDRULE":
+			if pending[directive] == 0 {
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return "", errorf("invalid %s count: %v", directive, err)
+				}
+				pending[directive] = n
+				continue
+			}
+			pending[directive]--
+			switch directive {
+			case "AF":
+				flags, err := h.flagType.parseFlags(args[0])
+				if err != nil {
+					return "", errorf("%v", err)
+				}
+				h.aliases = append(h.aliases, flags)
+			case "REP":
+				if len(args) < 2 {
+					return "", errorf("REP needs two arguments")
+				}
+				// underscores stand for spaces in replacements
+				from := strings.Replace(args[0], "_", " ", -1)
+				to := strings.Replace(args[1], "_", " ", -1)
+				h.rep = append(h.rep, [2]string{from, to})
+			case "MAP":
+				var group []string
+				for s := args[0]; len(s) > 0; {
+					if s[0] == '(' {
+						end := strings.IndexByte(s, ')')
+						if end < 0 {
+							return "", errorf("unterminated parenthesis in MAP")
+						}
+						group = append(group, s[1:end])
+						s = s[end+1:]
+						continue
+					}
+					_, size := utf8.DecodeRuneInString(s)
+					group = append(group, s[:size])
+					s = s[size:]
+				}
+				h.maps = append(h.maps, group)
+			case "COMPOUNDRULE":
+				rule, err := parseCompoundRule(args[0], h.flagType)
+				if err != nil {
+					return "", errorf("%v", err)
+				}
+				h.compoundRules = append(h.compoundRules, rule)
+			}
+		case "PFX", "SFX":
+			if len(args) < 3 {
+				return "", errorf("%s needs at least three arguments", directive)
+			}
+			if pending[directive] == 0 {
+				n, err := strconv.Atoi(args[2])
+				if err != nil {
+					return "", errorf("invalid %s count: %v", directive, err)
+				}
+				pending[directive] = n
+				lastAffix.flag = parseFlag(args[0])
+				lastAffix.cross = args[1] == "Y"
+				break
+			}
+			pending[directive]--
+			if len(args) < 4 {
+				args = append(args, ".")
+			}
+			a := &affix{flag: lastAffix.flag, crossProduct: lastAffix.cross}
+			if args[1] != "0" {
+				a.strip = args[1]
+			}
+			add := args[2]
+			if i := strings.IndexByte(add, '/'); i >= 0 {
+				contFlags, err := h.parseFlagsOrAlias(add[i+1:])
+				if err != nil {
+					return "", errorf("%v", err)
+				}
+				a.contFlags = contFlags
+				add = add[:i]
+			}
+			if add != "0" {
+				a.add = add
+			}
+			cond, err := parseCondition(args[3])
+			if err != nil {
+				return "", errorf("%v", err)
+			}
+			a.cond = cond
+			if directive == "PFX" {
+				h.prefixes[a.add] = append(h.prefixes[a.add], a)
+			} else {
+				h.suffixes[a.add] = append(h.suffixes[a.add], a)
+			}
+		}
+		if flagErr != nil {
+			return "", errorf("%v", flagErr)
+		}
+	}
+	return encoding, scanner.Err()
+}
+
+// parseFlagsOrAlias parses flags of a word or affix, which may be a number
+// referring to a flag vector defined with AF.
+func (h *Hunspell) parseFlagsOrAlias(s string) ([]string, error) {
+	if len(h.aliases) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > len(h.aliases) {
+			return nil, fmt.Errorf("invalid flag alias %q", s)
+		}
+		return h.aliases[n-1], nil
+	}
+	return h.flagType.parseFlags(s)
+}
+
+// readDic reads the words of a dictionary file.
+func (h *Hunspell) readDic(r io.Reader, encoding string) error {
+	decode, err := decoderFor(encoding)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := decode(scanner.Text())
+		if lineno == 1 {
+			// the first line has the approximate number of words
+			continue
+		}
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		word, flags, err := h.parseDicEntry(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineno, err)
+		}
+		h.words[word] = append(h.words[word], flags)
+	}
+	return scanner.Err()
+}
+
+// parseDicEntry splits an entry like "word/FLAGS" in a word and its flags.
+// A slash that is part of the word is escaped with a backslash.
+func (h *Hunspell) parseDicEntry(s string) (string, []string, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			word := strings.Replace(s[:i], `\/`, "/", -1)
+			flags, err := h.parseFlagsOrAlias(s[i+1:])
+			return word, flags, err
+		}
+	}
+	return strings.Replace(s, `\/`, "/", -1), nil, nil
+}
+
+// lookup controls which dictionary words are acceptable in a check.
+type lookup struct {
+	caseVariant bool // the word is a case variant of the checked word
+	suggest     bool // the word is a suggestion candidate
+}
+
+// Check returns true if word is spelled correctly.
+func (h *Hunspell) Check(word string) bool {
+	return h.checkCase(word, lookup{})
+}
+
+func (h *Hunspell) checkCase(word string, l lookup) bool {
+	if h.check(word, l) {
+		return true
+	}
+	l.caseVariant = true
+	switch caseOf(word) {
+	case titleCase:
+		return h.check(strings.ToLower(word), l)
+	case upperCase:
+		lower := strings.ToLower(word)
+		return h.check(lower, l) || h.check(capitalize(lower), l)
+	}
+	return false
+}
+
+func (h *Hunspell) check(word string, l lookup) bool {
+	for _, flags := range h.words[word] {
+		if hasFlag(flags, h.forbiddenWord) {
+			return false
+		}
+	}
+	for _, flags := range h.words[word] {
+		if h.validRoot(flags, l) && !hasFlag(flags, h.needAffix) && !hasFlag(flags, h.onlyInCompound) {
+			return true
+		}
+	}
+	return h.checkAffixed(word, l) || h.checkCompound(word, l)
+}
+
+// validRoot returns true if a dictionary word with flags can be used to build
+// the checked word.
+func (h *Hunspell) validRoot(flags []string, l lookup) bool {
+	switch {
+	case hasFlag(flags, h.forbiddenWord):
+		return false
+	case l.caseVariant && hasFlag(flags, h.keepCase):
+		return false
+	case l.suggest && hasFlag(flags, h.noSuggest):
+		return false
+	}
+	return true
+}
+
+// hasRoot returns true if stem is a dictionary word having all flags.
+func (h *Hunspell) hasRoot(stem string, l lookup, flags ...string) bool {
+	for _, f := range h.words[stem] {
+		if !h.validRoot(f, l) || hasFlag(f, h.onlyInCompound) {
+			continue
+		}
+		ok := true
+		for _, flag := range flags {
+			ok = ok && hasFlag(f, flag)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAffixed returns true if word is a dictionary word with a prefix, a
+// suffix, both or two suffixes applied.
+func (h *Hunspell) checkAffixed(word string, l lookup) bool {
+	for _, s := range h.suffixesOf(word) {
+		stem := word[:len(word)-len(s.add)] + s.strip
+		if !s.matchSuffix(stem) {
+			continue
+		}
+		if h.hasRoot(stem, l, s.flag) {
+			return true
+		}
+		// the stem itself may be a suffixed word whose suffix allows s
+		for _, s2 := range h.suffixesOf(stem) {
+			if !hasFlag(s2.contFlags, s.flag) {
+				continue
+			}
+			root := stem[:len(stem)-len(s2.add)] + s2.strip
+			if s2.matchSuffix(root) && h.hasRoot(root, l, s2.flag) {
+				return true
+			}
+		}
+	}
+	for _, p := range h.prefixesOf(word) {
+		stem := p.strip + word[len(p.add):]
+		if !p.matchPrefix(stem) {
+			continue
+		}
+		if h.hasRoot(stem, l, p.flag) {
+			return true
+		}
+		if !p.crossProduct {
+			continue
+		}
+		for _, s := range h.suffixesOf(stem) {
+			if !s.crossProduct {
+				continue
+			}
+			root := stem[:len(stem)-len(s.add)] + s.strip
+			if s.matchSuffix(root) && h.hasRoot(root, l, p.flag, s.flag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// suffixesOf returns the suffix rules that could have produced word.
+func (h *Hunspell) suffixesOf(word string) []*affix {
+	var r []*affix
+	for i := 0; i <= len(word); i++ {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		add := word[i:]
+		if add == word && add != "" {
+			// a suffix must leave something of the stem
+			continue
+		}
+		r = append(r, h.suffixes[add]...)
+	}
+	return r
+}
+
+// prefixesOf returns the prefix rules that could have produced word.
+func (h *Hunspell) prefixesOf(word string) []*affix {
+	var r []*affix
+	for i := 0; i < len(word); i++ {
+		if i > 0 && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		r = append(r, h.prefixes[word[:i]]...)
+	}
+	return r
+}
+
+// checkCompound returns true if word is a compound of dictionary words,
+// according to COMPOUNDFLAG or COMPOUNDRULE.
+func (h *Hunspell) checkCompound(word string, l lookup) bool {
+	if h.compoundFlag == "" && len(h.compoundRules) == 0 {
+		return false
+	}
+	return h.splitCompound(word, nil, l)
+}
+
+// splitCompound tries every way of splitting word into parts of at least
+// COMPOUNDMIN characters, given the flags of the parts already split.
+func (h *Hunspell) splitCompound(word string, parts [][][]string, l lookup) bool {
+	runes := []rune(word)
+	for i := h.compoundMin; i <= len(runes); i++ {
+		rest := string(runes[i:])
+		if rest != "" && utf8.RuneCountInString(rest) < h.compoundMin {
+			continue
+		}
+		var homonyms [][]string
+		for _, flags := range h.words[string(runes[:i])] {
+			if h.validRoot(flags, l) && !hasFlag(flags, h.needAffix) {
+				homonyms = append(homonyms, flags)
+			}
+		}
+		if len(homonyms) == 0 {
+			continue
+		}
+		split := append(parts[:len(parts):len(parts)], homonyms)
+		if rest == "" {
+			if len(split) > 1 && h.isCompound(split) {
+				return true
+			}
+			continue
+		}
+		if h.splitCompound(rest, split, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompound returns true if the parts can form a compound word.
+func (h *Hunspell) isCompound(parts [][][]string) bool {
+	if h.compoundFlag != "" {
+		all := true
+		for _, homonyms := range parts {
+			found := false
+			for _, flags := range homonyms {
+				found = found || hasFlag(flags, h.compoundFlag)
+			}
+			all = all && found
+		}
+		if all {
+			return true
+		}
+	}
+	for _, rule := range h.compoundRules {
+		if rule.match(parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest returns suggestions for a misspelled word, using the REP and MAP
+// tables, simple edits with the TRY characters and, as a last resort, words
+// sharing n-grams with word.
+func (h *Hunspell) Suggest(word string) []string {
+	var r []string
+	seen := map[string]bool{word: true}
+	l := lookup{suggest: true}
+	add := func(candidate string) {
+		if seen[candidate] || len(r) >= MaxSuggestions {
+			return
+		}
+		seen[candidate] = true
+		for _, w := range strings.Split(candidate, " ") {
+			if w == "" || !h.checkCase(w, l) {
+				return
+			}
+		}
+		r = append(r, candidate)
+	}
+	for _, rep := range h.rep {
+		for i := 0; ; {
+			j := strings.Index(word[i:], rep[0])
+			if j < 0 || rep[0] == "" {
+				break
+			}
+			i += j
+			add(word[:i] + rep[1] + word[i+len(rep[0]):])
+			i += len(rep[0])
+		}
+	}
+	for _, group := range h.maps {
+		for _, from := range group {
+			for i := 0; ; {
+				j := strings.Index(word[i:], from)
+				if j < 0 {
+					break
+				}
+				i += j
+				for _, to := range group {
+					add(word[:i] + to + word[i+len(from):])
+				}
+				i += len(from)
+			}
+		}
+	}
+	for _, e := range edits(word, h.alphabet()) {
+		add(e)
+	}
+	runes := []rune(word)
+	for i := 1; i < len(runes); i++ {
+		add(string(runes[:i]) + " " + string(runes[i:]))
+	}
+	if len(r) == 0 {
+		for _, s := range h.ngramSuggestions(word) {
+			add(applyCase(s, caseOf(word)))
+		}
+	}
+	return r
+}
+
+// edits returns all strings one deletion, transposition, replacement or
+// insertion of a character in alphabet away from word.
+func edits(word string, alphabet []rune) []string {
+	runes := []rune(word)
+	var r []string
+	for i := 0; i <= len(runes); i++ {
+		head, tail := string(runes[:i]), runes[i:]
+		if len(tail) > 0 {
+			r = append(r, head+string(tail[1:]))
+		}
+		if len(tail) > 1 {
+			r = append(r, head+string(tail[1])+string(tail[0])+string(tail[2:]))
+		}
+		for _, c := range alphabet {
+			if len(tail) > 0 && c != tail[0] {
+				r = append(r, head+string(c)+string(tail[1:]))
+			}
+			r = append(r, head+string(c)+string(tail))
+		}
+	}
+	return r
+}
+
+// alphabet returns the characters tried in edits, as defined by TRY.
+func (h *Hunspell) alphabet() []rune {
+	if len(h.try) > 0 {
+		return h.try
+	}
+	return []rune("abcdefghijklmnopqrstuvwxyz")
+}
+
+// ngramSuggestions returns the dictionary words most similar to word.
+func (h *Hunspell) ngramSuggestions(word string) []string {
+	type scored struct {
+		word  string
+		score float64
+	}
+	lower := strings.ToLower(word)
+	var candidates []scored
+	for w, homonyms := range h.words {
+		ok := false
+		for _, flags := range homonyms {
+			ok = ok || (!hasFlag(flags, h.forbiddenWord) && !hasFlag(flags, h.noSuggest) &&
+				!hasFlag(flags, h.onlyInCompound) && !hasFlag(flags, h.needAffix))
+		}
+		if !ok {
+			continue
+		}
+		if score := bigramSimilarity(lower, strings.ToLower(w)); score >= 0.5 {
+			candidates = append(candidates, scored{w, score})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].word < candidates[j].word
+	})
+	var r []string
+	for i := 0; i < len(candidates) && i < MaxSuggestions; i++ {
+		r = append(r, candidates[i].word)
+	}
+	return r
+}
+
+// bigramSimilarity returns the Dice coefficient of the character bigrams of
+// a and b, a number between 0 and 1.
+func bigramSimilarity(a, b string) float64 {
+	bigrams := func(s string) map[string]int {
+		m := make(map[string]int)
+		runes := []rune(s)
+		for i := 0; i+1 < len(runes); i++ {
+			m[string(runes[i:i+2])]++
+		}
+		return m
+	}
+	ma, mb := bigrams(a), bigrams(b)
+	total, common := 0, 0
+	for k, n := range ma {
+		total += n
+		if m := mb[k]; m < n {
+			common += m
+		} else {
+			common += n
+		}
+	}
+	for _, n := range mb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(2*common) / float64(total)
+}
@@ -0,0 +1,144 @@
+package spell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func loadTestHunspell(t *testing.T) *Hunspell {
+	h, err := LoadHunspell("testdata/hunspell/en_test.aff", "testdata/hunspell/en_test.dic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHunspellCheck(t *testing.T) {
+	h := loadTestHunspell(t)
+	for _, tt := range []struct {
+		word string
+		want bool
+	}{
+		// dictionary words and case variants
+		{"check", true},
+		{"Check", true},
+		{"CHECK", true},
+		{"chek", false},
+		// suffixes with conditions and stripping
+		{"checks", true},
+		{"checked", true},
+		{"tries", true},
+		{"tried", true},
+		{"trys", false},
+		{"cafes", true},
+		{"happiness", true},
+		// prefixes and cross products
+		{"recheck", true},
+		{"unlocked", true},
+		{"unhappiness", true},
+		{"undo", true},
+		{"redo", false},
+		// continuation classes
+		{"lockable", true},
+		{"lockables", true},
+		// compounds
+		{"football", true},
+		{"footballs", false},
+		{"ballfoot", true},
+		{"1st", true},
+		{"st", false},
+		// KEEPCASE, FORBIDDENWORD and NEEDAFFIX
+		{"iPod", true},
+		{"IPod", false},
+		{"ipod", false},
+		{"colour", false},
+		{"pseudo", false},
+		{"dammit", true},
+	} {
+		if got := h.Check(tt.word); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestHunspellSuggest(t *testing.T) {
+	h := loadTestHunspell(t)
+	for _, tt := range []struct {
+		word string
+		want []string
+	}{
+		{"fone", []string{"phone"}},    // REP
+		{"café", []string{"cafe"}},     // MAP
+		{"chekc", []string{"check"}},   // swap
+		{"Lokced", []string{"Locked"}}, // swap with affix and case
+		{"damit", nil},                 // NOSUGGEST
+		{"trycheck", []string{"try check"}},
+	} {
+		if got := h.Suggest(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tt := range []struct {
+		t    flagType
+		s    string
+		want []string
+	}{
+		{singleFlag, "ABc", []string{"A", "B", "c"}},
+		{singleFlag, "áé", []string{"á", "é"}},
+		{longFlag, "AaBb", []string{"Aa", "Bb"}},
+		{numFlag, "1,23,456", []string{"1", "23", "456"}},
+	} {
+		got, err := tt.t.parseFlags(tt.s)
+		if err != nil {
+			t.Errorf("parseFlags(%q): %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFlags(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+	if _, err := longFlag.parseFlags("AaB"); err == nil {
+		t.Errorf("parseFlags(%q) with long flags returned err=nil", "AaB")
+	}
+}
+
+func TestCompoundRuleMatch(t *testing.T) {
+	rule, err := parseCompoundRule("n*t?e", singleFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := func(flags ...string) [][]string { return [][]string{flags} }
+	for _, tt := range []struct {
+		parts [][][]string
+		want  bool
+	}{
+		{[][][]string{part("e")}, true},
+		{[][][]string{part("n"), part("n"), part("e")}, true},
+		{[][][]string{part("n"), part("t"), part("e")}, true},
+		{[][][]string{part("t"), part("t"), part("e")}, false},
+		{[][][]string{part("n"), part("t")}, false},
+	} {
+		if got := rule.match(tt.parts); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDictionary(t *testing.T) {
+	for path, want := range map[string]interface{}{
+		"testdata/words.txt":            &WordList{},
+		"testdata/hunspell/en_test.dic": &Hunspell{},
+	} {
+		dict, err := LoadDictionary(path)
+		if err != nil {
+			t.Errorf("LoadDictionary(%q): %v", path, err)
+			continue
+		}
+		if got, want := reflect.TypeOf(dict), reflect.TypeOf(want); got != want {
+			t.Errorf("LoadDictionary(%q) returned a %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package spell
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// Dictionary is implemented by any value that can tell whether a word is
+// spelled correctly and suggest replacements for misspelled words.
+type Dictionary interface {
+	Check(word string) bool
+	Suggest(word string) []string
+}
+
+// LoadDictionary loads a dictionary from path. Files with the .dic extension
+// are loaded as Hunspell dictionaries, together with the .aff file with the
+// same base name. Other files are loaded as word lists.
+func LoadDictionary(path string) (Dictionary, error) {
+	if filepath.Ext(path) == ".dic" {
+		return LoadHunspell(strings.TrimSuffix(path, ".dic")+".aff", path)
+	}
+	return LoadWordList(path)
+}
+
+// Checker finds potential misspells in documentation text.
+type Checker struct {
+	Dictionary Dictionary
+	ignored    map[string]bool
+}
+
+// NewChecker creates a new Checker that looks up words in dict.
+func NewChecker(dict Dictionary) *Checker {
+	return &Checker{Dictionary: dict, ignored: make(map[string]bool)}
+}
+
+// Ignore makes the checker accept words regardless of the dictionary.
+func (c *Checker) Ignore(words ...string) {
+	for _, word := range words {
+		c.ignored[word] = true
+	}
+}
+
+// Check returns the potential misspells in content.
+func (c *Checker) Check(content string) []*types.Misspelling {
+	return c.check(content, nil)
+}
+
+// Spellcheck fills in the misspellings of every documentation text in pkg,
+// ignoring the package identifiers, and returns the texts that have potential
+// misspells. Texts that are identifiers are split into words with
+// SplitIdentifier, and misspells in exported identifiers are Major; the
+// identifier itself is not ignored, or single-word names would never be
+// checked. A nil package, like a null line of a stream, and nil texts have no
+// misspells.
+func (c *Checker) Spellcheck(pkg *types.Package) []*types.Text {
+	if pkg == nil {
+		return nil
+	}
+	identifiers := make(map[string]bool, len(pkg.Identifiers))
+	for _, ident := range pkg.Identifiers {
+		identifiers[ident] = true
+	}
+	var misspelled []*types.Text
+	for _, text := range pkg.Documentation {
+		if text == nil {
+			continue
+		}
+		if text.Identifier {
+			self := identifiers[text.Content]
+			delete(identifiers, text.Content)
+			text.Misspellings = c.checkTokens(SplitIdentifier(text.Content), identifiers)
+			if self {
+				identifiers[text.Content] = true
+			}
+		} else {
+			text.Misspellings = c.check(text.Content, identifiers)
+		}
+		for _, m := range text.Misspellings {
+			m.Text = text
+			if text.Exported {
+				m.Severity = types.Major
+			}
+		}
+		if len(text.Misspellings) > 0 {
+			misspelled = append(misspelled, text)
+		}
+	}
+	return misspelled
+}
+
+// CheckIdentifier returns the potential misspells in the words of an
+// identifier.
+func (c *Checker) CheckIdentifier(name string) []*types.Misspelling {
+	return c.checkTokens(SplitIdentifier(name), nil)
+}
+
+func (c *Checker) check(content string, identifiers map[string]bool) []*types.Misspelling {
+	return c.checkTokens(Tokenize(content), identifiers)
+}
+
+func (c *Checker) checkTokens(tokens []Token, identifiers map[string]bool) []*types.Misspelling {
+	var r []*types.Misspelling
+	for _, token := range tokens {
+		if c.ignored[token.Word] || identifiers[token.Word] || c.Dictionary.Check(token.Word) {
+			continue
+		}
+		r = append(r, &types.Misspelling{
+			Word:        token.Word,
+			Offset:      token.Offset,
+			Suggestions: c.Dictionary.Suggest(token.Word),
+		})
+	}
+	return r
+}
+
+// A Token is a word found in some text.
+type Token struct {
+	Word   string
+	Offset int // byte offset of Word in the text
+}
+
+// Tokenize splits text into words that should be spellchecked. Like the
+// enchant filters used by spellcheck.py, it skips URLs, email addresses and
+// words containing digits.
+func Tokenize(text string) []Token {
+	var r []Token
+	for offset := 0; offset < len(text); {
+		// find the next whitespace-delimited chunk
+		i := strings.IndexFunc(text[offset:], isNotSpace)
+		if i < 0 {
+			break
+		}
+		begin := offset + i
+		end := len(text)
+		if j := strings.IndexFunc(text[begin:], unicode.IsSpace); j >= 0 {
+			end = begin + j
+		}
+		offset = end
+		chunk := text[begin:end]
+		if isURL(chunk) || isEmail(chunk) {
+			continue
+		}
+		r = append(r, tokenizeChunk(chunk, begin)...)
+	}
+	return r
+}
+
+// tokenizeChunk splits a chunk of text without spaces into words. The offset
+// of each word is relative to base.
+func tokenizeChunk(chunk string, base int) []Token {
+	var r []Token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := chunk[start:end]
+		begin := start
+		start = -1
+		// apostrophes only belong to a word when inside it
+		for len(word) > 0 {
+			q, size := utf8.DecodeRuneInString(word)
+			if !isApostrophe(q) {
+				break
+			}
+			word = word[size:]
+			begin += size
+		}
+		for len(word) > 0 {
+			q, size := utf8.DecodeLastRuneInString(word)
+			if !isApostrophe(q) {
+				break
+			}
+			word = word[:len(word)-size]
+		}
+		if word == "" || strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			return
+		}
+		r = append(r, Token{Word: word, Offset: base + begin})
+	}
+	for i, c := range chunk {
+		if unicode.IsLetter(c) || unicode.IsMark(c) || unicode.IsDigit(c) || isApostrophe(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(chunk))
+	return r
+}
+
+// SplitIdentifier splits an identifier into the words that should be
+// spellchecked. Words are separated by underscores and by changes of case, as
+// in camelCase, and acronyms end before the capital letter that begins the
+// next word, as in HTTPServer. Acronyms in mixed case identifiers and words
+// with a single letter or with digits are skipped.
+func SplitIdentifier(name string) []Token {
+	var r []Token
+	mixedCase := strings.ToUpper(name) != name
+	add := func(begin, end int) {
+		word := name[begin:end]
+		if utf8.RuneCountInString(word) < 2 || strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			return
+		}
+		if mixedCase && strings.ToUpper(word) == word {
+			return
+		}
+		r = append(r, Token{Word: word, Offset: begin})
+	}
+	runes := []rune(name)
+	begin, offset := 0, 0
+	for i, c := range runes {
+		size := utf8.RuneLen(c)
+		switch {
+		case c == '_':
+			add(begin, offset)
+			begin = offset + size
+		case i > 0 && offset > begin:
+			prev := runes[i-1]
+			var next rune
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			if unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) ||
+				unicode.IsUpper(c) && unicode.IsUpper(prev) && unicode.IsLower(next) ||
+				unicode.IsDigit(c) != unicode.IsDigit(prev) {
+				add(begin, offset)
+				begin = offset
+			}
+		}
+		offset += size
+	}
+	add(begin, len(name))
+	return r
+}
+
+func isNotSpace(r rune) bool {
+	return !unicode.IsSpace(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// isURL returns true if s looks like a URL.
+func isURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(s, "www.")
+}
+
+// isEmail returns true if s looks like an email address.
+func isEmail(s string) bool {
+	at := strings.Index(s, "@")
+	return at > 0 && strings.Contains(s[at+1:], ".")
+}
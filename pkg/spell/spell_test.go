@@ -0,0 +1,194 @@
+package spell
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestSpellcheck(t *testing.T) {
+	dict, err := LoadWordList("testdata/words.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("../../testdata/read.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var words [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var pkg *types.Package
+		if err := json.Unmarshal(scanner.Bytes(), &pkg); err != nil {
+			t.Fatal(err)
+		}
+		for _, text := range NewChecker(dict).Spellcheck(pkg) {
+			var w []string
+			for _, m := range text.Misspellings {
+				w = append(w, m.Word)
+			}
+			words = append(words, w)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"interfeice"},
+		{"typokiller"},
+		{"typokiller"},
+		{"helo", "Gophr"},
+	}
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("misspelled words = %q, want %q", words, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := NewChecker(NewWordList("hello", "gopher", "interface"))
+	c.Ignore("typokiller")
+	misspellings := c.Check("Hello typokiller Gophr, see http://example.com or mail gopher@example.com about the interfeice")
+	var got []types.Misspelling
+	for _, m := range misspellings {
+		got = append(got, *m)
+	}
+	want := []types.Misspelling{
+		{Word: "Gophr", Offset: 17, Suggestions: []string{"Gopher"}},
+		{Word: "see", Offset: 24},
+		{Word: "or", Offset: 47},
+		{Word: "mail", Offset: 50},
+		{Word: "about", Offset: 74},
+		{Word: "the", Offset: 80},
+		{Word: "interfeice", Offset: 84, Suggestions: []string{"interface"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want []Token
+	}{
+		{"", nil},
+		{"// A Gopher.", []Token{{"A", 3}, {"Gopher", 5}}},
+		{"fmt.Stringer", []Token{{"fmt", 0}, {"Stringer", 4}}},
+		{"don't 'quote' it", []Token{{"don't", 0}, {"quote", 7}, {"it", 14}}},
+		{"utf8 is 2x faster", []Token{{"is", 5}, {"faster", 11}}},
+		{"see www.example.com", []Token{{"see", 0}}},
+		{"\tcafé au lait", []Token{{"café", 1}, {"au", 7}, {"lait", 10}}},
+	} {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWordListCheck(t *testing.T) {
+	wl := NewWordList("gopher", "Go", "NASA")
+	for _, tt := range []struct {
+		word string
+		want bool
+	}{
+		{"gopher", true},
+		{"Gopher", true},
+		{"GOPHER", true},
+		{"GoPher", false},
+		{"go", false},
+		{"GO", true},
+		{"NASA", true},
+		{"nasa", false},
+	} {
+		if got := wl.Check(tt.word); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestWordListSuggest(t *testing.T) {
+	wl := NewWordList("hello", "help", "hero", "held", "world")
+	for _, tt := range []struct {
+		word string
+		want []string
+	}{
+		{"helo", []string{"held", "hello", "help", "hero"}},
+		{"Wrold", []string{"World"}},
+		{"hlp", []string{"help", "held"}},
+		{"HELOO", []string{"HELLO", "HELD", "HELP", "HERO"}},
+		{"xyzzy", nil},
+	} {
+		if got := wl.Suggest(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want []Token
+	}{
+		{"RecieveBuffer", []Token{{"Recieve", 0}, {"Buffer", 7}}},
+		{"maxRetires", []Token{{"max", 0}, {"Retires", 3}}},
+		{"HTTPServer", []Token{{"Server", 4}}},
+		{"parseURL", []Token{{"parse", 0}}},
+		{"max_retry_count", []Token{{"max", 0}, {"retry", 4}, {"count", 10}}},
+		{"MAX_RETIRES", []Token{{"MAX", 0}, {"RETIRES", 4}}},
+		{"utf8Decoder", []Token{{"utf", 0}, {"Decoder", 4}}},
+		{"x", nil},
+		{"_", nil},
+		{"señalFuerte", []Token{{"señal", 0}, {"Fuerte", 6}}},
+	} {
+		if got := SplitIdentifier(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitIdentifier(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpellcheckIdentifiers(t *testing.T) {
+	c := NewChecker(NewWordList("receive", "buffer", "max", "retries", "length"))
+	pkg := &types.Package{
+		Identifiers: []string{"RecieveBuffer", "maxRetires", "lenght"},
+		Documentation: []*types.Text{
+			{Content: "RecieveBuffer", Identifier: true, Exported: true},
+			{Content: "maxRetires", Identifier: true},
+			{Content: "lenght", Identifier: true},
+		},
+	}
+	texts := c.Spellcheck(pkg)
+	if len(texts) != 3 {
+		t.Fatalf("Spellcheck returned %d texts, want 3", len(texts))
+	}
+	for i, want := range []types.Misspelling{
+		{Word: "Recieve", Offset: 0, Suggestions: []string{"Receive"}, Severity: types.Major},
+		{Word: "Retires", Offset: 3, Suggestions: []string{"Retries"}, Severity: types.Minor},
+		{Word: "lenght", Offset: 0, Suggestions: []string{"length"}, Severity: types.Minor},
+	} {
+		if len(texts[i].Misspellings) != 1 {
+			t.Errorf("texts[%d] has %d misspellings, want 1", i, len(texts[i].Misspellings))
+			continue
+		}
+		got := *texts[i].Misspellings[0]
+		got.Text = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("texts[%d].Misspellings[0] = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestSpellcheckNil(t *testing.T) {
+	c := NewChecker(NewWordList("the"))
+	if texts := c.Spellcheck(nil); texts != nil {
+		t.Errorf("Spellcheck(nil) = %+v, want nil", texts)
+	}
+	pkg := &types.Package{Documentation: []*types.Text{nil, {Content: "teh"}}}
+	if texts := c.Spellcheck(pkg); len(texts) != 1 {
+		t.Errorf("Spellcheck got %d texts, want 1", len(texts))
+	}
+}
@@ -0,0 +1,91 @@
+package spell
+
+import "sort"
+
+// trie is a prefix tree of words, searched for the words a few edits away from
+// a misspelled word without generating every possible edit.
+type trie struct {
+	edges []trieEdge // sorted by rune
+	word  bool
+}
+
+type trieEdge struct {
+	r    rune
+	node *trie
+}
+
+// add adds word to t.
+func (t *trie) add(word string) {
+	for _, r := range word {
+		i := sort.Search(len(t.edges), func(i int) bool { return t.edges[i].r >= r })
+		if i == len(t.edges) || t.edges[i].r != r {
+			t.edges = append(t.edges, trieEdge{})
+			copy(t.edges[i+1:], t.edges[i:])
+			t.edges[i] = trieEdge{r, &trie{}}
+		}
+		t = t.edges[i].node
+	}
+	t.word = true
+}
+
+// search calls fn for every word in t at most max deletions, transpositions,
+// replacements or insertions of a character away from word, with the number
+// of edits.
+func (t *trie) search(word string, max int, fn func(word string, edits int)) {
+	runes := []rune(word)
+	row := make([]int, len(runes)+1)
+	for i := range row {
+		row[i] = i
+	}
+	if t.word && row[len(runes)] <= max {
+		fn("", row[len(runes)])
+	}
+	for _, e := range t.edges {
+		e.node.walk(runes, []rune{e.r}, nil, row, max, fn)
+	}
+}
+
+// walk continues a search of word in the subtree t, reached through prefix.
+// prev and prev2 are the edit distances between the prefixes of word and
+// prefix without its last one and two characters, as in the Wagner–Fischer
+// algorithm extended with transpositions of adjacent characters. Subtrees
+// whose prefixes are more than max edits away from every prefix of word are
+// skipped.
+func (t *trie) walk(word, prefix []rune, prev2, prev []int, max int, fn func(word string, edits int)) {
+	n := len(prefix)
+	c := prefix[n-1]
+	row := make([]int, len(word)+1)
+	row[0] = prev[0] + 1
+	least := row[0]
+	for i := 1; i <= len(word); i++ {
+		cost := 1
+		if word[i-1] == c {
+			cost = 0
+		}
+		row[i] = minInt(row[i-1]+1, prev[i]+1, prev[i-1]+cost)
+		if prev2 != nil && i > 1 && word[i-1] == prefix[n-2] && word[i-2] == c {
+			row[i] = minInt(row[i], prev2[i-2]+1)
+		}
+		least = minInt(least, row[i])
+	}
+	if t.word && row[len(word)] <= max {
+		fn(string(prefix), row[len(word)])
+	}
+	// a transposition may still bring the next row within max
+	if least > max && minInt(prev...)+1 > max {
+		return
+	}
+	for _, e := range t.edges {
+		e.node.walk(word, append(prefix, e.r), prev, row, max, fn)
+	}
+}
+
+func minInt(n ...int) int {
+	m := n[0]
+	for _, x := range n[1:] {
+		if x < m {
+			m = x
+		}
+	}
+	return m
+}
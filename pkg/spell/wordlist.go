@@ -0,0 +1,160 @@
+package spell
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxSuggestions limits how many suggestions are offered for a misspell.
+const MaxSuggestions = 10
+
+// WordList is a Dictionary backed by a plain list of words, such as
+// /usr/share/dict/words.
+type WordList struct {
+	words map[string]bool
+	// lower holds the words that can be suggested, in lower case.
+	lower trie
+}
+
+// NewWordList creates a new WordList with the given words.
+func NewWordList(words ...string) *WordList {
+	wl := &WordList{words: make(map[string]bool)}
+	wl.Add(words...)
+	return wl
+}
+
+// LoadWordList reads a word list with one word per line from path.
+func LoadWordList(path string) (*WordList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wl := NewWordList()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		wl.Add(word)
+	}
+	return wl, scanner.Err()
+}
+
+// Add adds words to the list.
+func (wl *WordList) Add(words ...string) {
+	for _, word := range words {
+		wl.words[word] = true
+		if lower := strings.ToLower(word); word == lower || word == capitalize(lower) {
+			wl.lower.add(lower)
+		}
+	}
+}
+
+// Check returns true if word is in the list. Capitalized and upper case words
+// are also accepted if their lower case form is in the list.
+func (wl *WordList) Check(word string) bool {
+	if wl.words[word] {
+		return true
+	}
+	switch caseOf(word) {
+	case titleCase:
+		return wl.words[strings.ToLower(word)]
+	case upperCase:
+		lower := strings.ToLower(word)
+		return wl.words[lower] || wl.words[capitalize(lower)]
+	}
+	return false
+}
+
+// Suggest returns words in the list that are at most two edits away from
+// word, closest first. Like in most spellcheckers, no character is edited
+// twice, so that a transposition and another edit of the transposed
+// characters count as three edits.
+func (wl *WordList) Suggest(word string) []string {
+	c := caseOf(word)
+	var byEdits [3][]string
+	wl.lower.search(strings.ToLower(word), 2, func(candidate string, n int) {
+		if match, ok := wl.match(candidate); ok && match != word {
+			byEdits[n] = append(byEdits[n], match)
+		}
+	})
+	var r []string
+	for _, found := range byEdits {
+		sort.Strings(found)
+		r = append(r, found...)
+	}
+	if len(r) > MaxSuggestions {
+		r = r[:MaxSuggestions]
+	}
+	for i, s := range r {
+		r[i] = applyCase(s, c)
+	}
+	return r
+}
+
+// match returns the form of candidate, a lower case word, present in the
+// list.
+func (wl *WordList) match(candidate string) (string, bool) {
+	if wl.words[candidate] {
+		return candidate, true
+	}
+	if title := capitalize(candidate); wl.words[title] {
+		return title, true
+	}
+	return "", false
+}
+
+type wordCase int
+
+const (
+	lowerCase wordCase = iota
+	titleCase
+	upperCase
+	mixedCase
+)
+
+// caseOf classifies the capitalization of word.
+func caseOf(word string) wordCase {
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	switch {
+	case upper == 0:
+		return lowerCase
+	case lower == 0 && upper > 1:
+		return upperCase
+	case upper == 1 && unicode.IsUpper(first):
+		return titleCase
+	}
+	return mixedCase
+}
+
+// capitalize returns word with its first letter in upper case.
+func capitalize(word string) string {
+	first, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(first)) + word[size:]
+}
+
+// applyCase capitalizes word to match the case c of the misspelled word.
+func applyCase(word string, c wordCase) string {
+	switch c {
+	case titleCase:
+		return capitalize(word)
+	case upperCase:
+		return strings.ToUpper(word)
+	}
+	return word
+}
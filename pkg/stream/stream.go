@@ -0,0 +1,174 @@
+package stream
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// Version is the version of the format of streams, described by Schema. It
+// changes only when streams cannot be read as before.
+const Version = 1
+
+// SchemaID is the identifier of Schema, written in the header of streams.
+const SchemaID = "https://github.com/rhcarvalho/typokiller/pkg/stream/schema.json"
+
+// Schema is the JSON Schema of each line of a stream: a header, first, and
+// then a package per line.
+//
+//go:embed schema.json
+var Schema string
+
+// record is a line of a stream, either a header or a package.
+type record struct {
+	*types.Header
+	*types.Package
+}
+
+// Encoder writes a stream of packages in JSON, one per line, after a header.
+type Encoder struct {
+	enc    *json.Encoder
+	header *types.Header // to be written before the first package
+}
+
+// NewEncoder creates a new Encoder that writes to w, naming tool as the writer
+// in the header.
+func NewEncoder(w io.Writer, tool string) *Encoder {
+	return &Encoder{
+		enc:    json.NewEncoder(w),
+		header: &types.Header{Schema: SchemaID, Version: Version, Tool: tool},
+	}
+}
+
+// Encode writes pkg, preceded by the header if it is the first package.
+func (e *Encoder) Encode(pkg *types.Package) error {
+	if e.header != nil {
+		if err := e.enc.Encode(e.header); err != nil {
+			return err
+		}
+		e.header = nil
+	}
+	return e.enc.Encode(pkg)
+}
+
+// Decoder reads a stream of packages in JSON, one per line, as written by
+// typokiller read and spell. Packages are decoded as they arrive, with no limit
+// on their size.
+type Decoder struct {
+	dec    *json.Decoder
+	lines  *lineCounter
+	header *types.Header
+}
+
+// NewDecoder creates a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	lines := &lineCounter{r: r}
+	return &Decoder{dec: json.NewDecoder(lines), lines: lines}
+}
+
+// Header returns the last header read, or nil if none was read, like in
+// streams written before headers were introduced.
+func (d *Decoder) Header() *types.Header {
+	return d.header
+}
+
+// Decode reads the next package, skipping headers. It returns io.EOF at the
+// end of a complete stream, a *TruncatedError if the stream ends in the middle
+// of a package, and a *SyntaxError with the line number of malformed input or
+// of a header of an unsupported version.
+func (d *Decoder) Decode() (*types.Package, error) {
+	for {
+		var rec record
+		err := d.dec.Decode(&rec)
+		// offsets are counted after the byte at fault
+		switch e := err.(type) {
+		case nil:
+		case *json.SyntaxError:
+			return nil, &SyntaxError{Line: d.lines.line(e.Offset - 1), Err: err}
+		default:
+			if err == d.lines.err {
+				// io.EOF or an error reading the input
+				return nil, err
+			}
+			if err == io.ErrUnexpectedEOF {
+				return nil, &TruncatedError{Line: d.lines.line(d.lines.offset - 1)}
+			}
+			// the value could not be decoded, and it ends at the
+			// input offset
+			return nil, &SyntaxError{Line: d.lines.line(d.dec.InputOffset() - 1), Err: err}
+		}
+		if rec.Header != nil {
+			if err := checkHeader(rec.Header); err != nil {
+				return nil, &SyntaxError{Line: d.lines.line(d.dec.InputOffset() - 1), Err: err}
+			}
+			d.header = rec.Header
+			continue
+		}
+		if rec.Package == nil {
+			// null or {}
+			continue
+		}
+		return rec.Package, nil
+	}
+}
+
+// checkHeader returns an error if streams with header h cannot be read.
+func checkHeader(h *types.Header) error {
+	if h.Schema != SchemaID {
+		return fmt.Errorf("unknown schema %q, want %q", h.Schema, SchemaID)
+	}
+	if h.Version < 1 || h.Version > Version {
+		return fmt.Errorf("unsupported version %d written by %q, want version %d or earlier", h.Version, h.Tool, Version)
+	}
+	return nil
+}
+
+// SyntaxError is returned by Decode for malformed input.
+type SyntaxError struct {
+	Line int // line of the input, starting at 1
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// TruncatedError is returned by Decode when the input ends in the middle of a
+// package, for instance when the command that writes it fails.
+type TruncatedError struct {
+	Line int // line where the input ends
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("line %d: input truncated in the middle of a package", e.Line)
+}
+
+// lineCounter reads from r, keeping the offsets of the newlines read so far,
+// to find the line of an offset.
+type lineCounter struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+	err      error // last error reading r
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.err = err
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.newlines = append(c.newlines, c.offset+int64(i))
+		}
+	}
+	c.offset += int64(n)
+	return n, err
+}
+
+// line returns the line of the byte at offset, starting at 1.
+func (c *lineCounter) line(offset int64) int {
+	return sort.Search(len(c.newlines), func(i int) bool { return c.newlines[i] >= offset }) + 1
+}
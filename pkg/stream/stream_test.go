@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		names []string
+		err   error
+	}{
+		{"", nil, nil},
+		{"{\"PackageName\":\"a\"}\n{\"PackageName\":\"b\"}\n", []string{"a", "b"}, nil},
+		// the last newline is optional, and nulls are skipped
+		{"{\"PackageName\":\"a\"}\nnull\n{\"PackageName\":\"b\"}", []string{"a", "b"}, nil},
+		// packages may be larger than any buffer
+		{"{\"PackageName\":\"" + strings.Repeat("a", 1<<20) + "\"}\n", []string{strings.Repeat("a", 1<<20)}, nil},
+		{"{\"PackageName\":\"a\"}\n{\"PackageName\":\"b\"}\n{\"PackageName\":", []string{"a", "b"}, &TruncatedError{Line: 3}},
+		{"{\"PackageName\":\"a\"}\n{\"PackageName\":\"b\",\n", []string{"a"}, &TruncatedError{Line: 2}},
+		{"{\"PackageName\":\"a\"}\n\n{\"PackageName\":\"b\"]\n", []string{"a"}, &SyntaxError{Line: 3}},
+		{"{\"PackageName\":\"a\"}\n{\"PackageName\":1}\n", []string{"a"}, &SyntaxError{Line: 2}},
+	} {
+		dec := NewDecoder(strings.NewReader(tt.input))
+		var names []string
+		var err error
+		for {
+			pkg, e := dec.Decode()
+			if e != nil {
+				err = e
+				break
+			}
+			names = append(names, pkg.Name)
+		}
+		if len(names) != len(tt.names) {
+			t.Errorf("decoded %d packages, want %d", len(names), len(tt.names))
+		}
+		for i := range names {
+			if i < len(tt.names) && names[i] != tt.names[i] {
+				t.Errorf("package %d = %.20q, want %.20q", i, names[i], tt.names[i])
+			}
+		}
+		switch want := tt.err.(type) {
+		case nil:
+			if err != io.EOF {
+				t.Errorf("Decode(%.40q) returned err=%v, want %v", tt.input, err, io.EOF)
+			}
+		case *TruncatedError:
+			if got, ok := err.(*TruncatedError); !ok || *got != *want {
+				t.Errorf("Decode(%.40q) returned err=%v, want %v", tt.input, err, want)
+			}
+		case *SyntaxError:
+			if got, ok := err.(*SyntaxError); !ok || got.Line != want.Line {
+				t.Errorf("Decode(%.40q) returned err=%v, want error at line %d", tt.input, err, want.Line)
+			}
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	var b bytes.Buffer
+	enc := NewEncoder(&b, "test 1.0")
+	pkgs := []*types.Package{
+		{Name: "a", Documentation: []*types.Text{{
+			Content: "teh",
+			Misspellings: []*types.Misspelling{{
+				Word:     "teh",
+				Severity: types.Major,
+				Action:   types.Action{Type: types.Replace, Replacement: "the"},
+			}},
+		}}},
+		{Name: "b"},
+	}
+	for _, pkg := range pkgs {
+		if err := enc.Encode(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(b.String(), "\n")
+	if got, want := lines[0], `{"Schema":"`+SchemaID+`","Version":1,"Tool":"test 1.0"}`; got != want {
+		t.Errorf("header = %s, want %s", got, want)
+	}
+	if !strings.Contains(lines[1], `"Severity":"major","Action":{"Type":"replace","Replacement":"the"}`) {
+		t.Errorf("package = %s, want enums as strings", lines[1])
+	}
+
+	dec := NewDecoder(&b)
+	for _, want := range pkgs {
+		pkg, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pkg, want) {
+			t.Errorf("Decode() = %#v, want %#v", pkg, want)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Decode() returned err=%v, want %v", err, io.EOF)
+	}
+	if h := dec.Header(); h == nil || h.Tool != "test 1.0" {
+		t.Errorf("Header() = %#v, want header of test 1.0", h)
+	}
+}
+
+func TestDecodeVersions(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		line  int // of the error, if any
+	}{
+		// streams without header, with enums as numbers
+		{`{"PackageName":"a","Documentation":[{"Content":"x","Misspellings":[{"Word":"x","Severity":1,"Action":{"Type":2}}]}]}`, 0},
+		{`{"Schema":"` + SchemaID + `","Version":1}` + "\n" + `{"PackageName":"a"}`, 0},
+		{`{"Schema":"` + SchemaID + `","Version":2}` + "\n" + `{"PackageName":"a"}`, 1},
+		{`{"Schema":"other","Version":1}` + "\n" + `{"PackageName":"a"}`, 1},
+		{`{"PackageName":"a"}` + "\n" + `{"PackageName":"a","Documentation":[{"Misspellings":[{"Action":{"Type":"fix"}}]}]}`, 2},
+	} {
+		dec := NewDecoder(strings.NewReader(tt.input))
+		_, err := dec.Decode()
+		if tt.line == 0 {
+			if err != nil {
+				t.Errorf("Decode(%q) returned err=%v", tt.input, err)
+			}
+			continue
+		}
+		if err == nil {
+			_, err = dec.Decode()
+		}
+		if e, ok := err.(*SyntaxError); !ok || e.Line != tt.line {
+			t.Errorf("Decode(%q) returned err=%v, want error at line %d", tt.input, err, tt.line)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+// ValidationError is a problem found by Validate in a line of a stream.
+type ValidationError struct {
+	Line int // line of the input, starting at 1
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Validate reads a stream from r and checks that its headers are of a
+// supported version, that each line conforms to Schema and that misspelled
+// words are found at their offsets in the content of their texts. Like
+// Decoder, it accepts streams without a header, written before headers were
+// introduced, and skips null lines. It calls fn
+// for each problem found, and returns an error only if the stream cannot be
+// read to the end, like when it has malformed JSON.
+func Validate(r io.Reader, fn func(*ValidationError)) error {
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(SchemaID, strings.NewReader(Schema)); err != nil {
+		return err
+	}
+	headerSchema, err := c.Compile(SchemaID + "#/$defs/Header")
+	if err != nil {
+		return err
+	}
+	packageSchema, err := c.Compile(SchemaID + "#/$defs/Package")
+	if err != nil {
+		return err
+	}
+
+	lines := &lineCounter{r: r}
+	dec := json.NewDecoder(lines)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if e, ok := err.(*json.SyntaxError); ok {
+			return &SyntaxError{Line: lines.line(e.Offset - 1), Err: err}
+		}
+		if err == io.ErrUnexpectedEOF {
+			return &TruncatedError{Line: lines.line(lines.offset - 1)}
+		}
+		if err != nil {
+			return err
+		}
+		line := lines.line(dec.InputOffset() - 1)
+		report := func(err error) {
+			fn(&ValidationError{Line: line, Err: err})
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		// headers may also be found later, in concatenated streams
+		schema := packageSchema
+		if obj, ok := v.(map[string]interface{}); ok && obj["Schema"] != nil {
+			schema = headerSchema
+		}
+		if err := schema.Validate(v); err != nil {
+			if e, ok := err.(*jsonschema.ValidationError); ok {
+				err = leafError(e)
+			}
+			report(err)
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			report(err)
+			continue
+		}
+		if rec.Header != nil {
+			if err := checkHeader(rec.Header); err != nil {
+				report(err)
+			}
+			continue
+		}
+		for i, text := range rec.Package.Documentation {
+			checkText(text, func(err error) {
+				report(fmt.Errorf("/Documentation/%d: %v", i, err))
+			})
+		}
+	}
+}
+
+// checkText calls fn for each misspelling of text that is not found at its
+// offset, and if the source offsets do not match the content.
+func checkText(text *types.Text, fn func(error)) {
+	if text.SourceOffsets != nil && len(text.SourceOffsets) != len(text.Content)+1 {
+		fn(fmt.Errorf("SourceOffsets has %d offsets, want %d", len(text.SourceOffsets), len(text.Content)+1))
+	}
+	for i, m := range text.Misspellings {
+		end := m.Offset + len(m.Word)
+		if end > len(text.Content) || text.Content[m.Offset:end] != m.Word {
+			fn(fmt.Errorf("/Misspellings/%d: word %q not found at offset %d of Content", i, m.Word, m.Offset))
+		}
+	}
+}
+
+// leafError returns the innermost cause of a validation error, as the location
+// of the invalid value and a message.
+func leafError(e *jsonschema.ValidationError) error {
+	for len(e.Causes) > 0 {
+		e = e.Causes[0]
+	}
+	loc := e.InstanceLocation
+	if loc == "" {
+		loc = "/"
+	}
+	return fmt.Errorf("%s: %s", loc, e.Message)
+}
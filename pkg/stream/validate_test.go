@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rhcarvalho/typokiller/pkg/types"
+)
+
+func TestValidate(t *testing.T) {
+	header := `{"Schema":"` + SchemaID + `","Version":1,"Tool":"test"}`
+	pkg := `{"PackageName":"a","Identifiers":null,"Documentation":[{"Content":"see teh","Position":{"Filename":"a.go","Offset":0,"Line":1,"Column":1},"Misspellings":[{"Word":"teh","Offset":4,"Suggestions":["the"],"Severity":"minor","Action":{"Type":"replace","Replacement":"the"}}]}]}`
+	for _, tt := range []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{header + "\n" + pkg + "\n", nil},
+		// streams written before headers were introduced
+		{pkg + "\nnull\n" + pkg, nil},
+		{strings.Replace(header, `"Version":1`, `"Version":2`, 1), []string{"line 1: /Version: must be <= 1 but found 2"}},
+		{header + "\n" + strings.Replace(pkg, `"replace"`, `2`, 1), []string{"line 2: /Documentation/0/Misspellings/0/Action/Type: value must be one of \"undefined\", \"ignore\", \"replace\", \"rename\""}},
+		{header + "\n" + strings.Replace(pkg, `"Offset":4`, `"Offset":3`, 1), []string{"line 2: /Documentation/0: /Misspellings/0: word \"teh\" not found at offset 3 of Content"}},
+		{header + "\n\n" + strings.Replace(pkg, `"Word"`, `"Words"`, 1), []string{"line 3: /Documentation/0/Misspellings/0: missing properties: 'Word'"}},
+	} {
+		var got []string
+		err := Validate(strings.NewReader(tt.input), func(e *ValidationError) {
+			got = append(got, e.Error())
+		})
+		if err != nil {
+			t.Errorf("Validate(%.40q) returned err=%v", tt.input, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("Validate(%.40q) found %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	for _, input := range []string{header + "\n{", header + "\n}"} {
+		if err := Validate(strings.NewReader(input), func(*ValidationError) {}); err == nil {
+			t.Errorf("Validate(%q) returned err=nil, want error", input)
+		}
+	}
+}
+
+// TestSchemaEnums checks that the enumerations of Schema match those of the
+// types package.
+func TestSchemaEnums(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Enum []string
+			}
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(Schema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var actions, severities []string
+	for t := types.Undefined; t <= types.Rename; t++ {
+		actions = append(actions, t.String())
+	}
+	for s := types.Minor; s <= types.Major; s++ {
+		severities = append(severities, s.String())
+	}
+	for _, tt := range []struct {
+		def, property string
+		want          []string
+	}{
+		{"Action", "Type", actions},
+		{"Misspelling", "Severity", severities},
+	} {
+		got := schema.Defs[tt.def].Properties[tt.property].Enum
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("schema enum of %s.%s = %q, want %q", tt.def, tt.property, got, tt.want)
+		}
+	}
+}
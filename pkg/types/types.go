@@ -1,6 +1,12 @@
 package types
 
-import "go/token"
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"strconv"
+	"strings"
+)
 
 // Package holds the documentation of a Go package and a list of identifiers.
 // The identifiers are useful to avoid false positives when spellchecking the
@@ -9,14 +15,48 @@ type Package struct {
 	Name          string `json:"PackageName"`
 	Identifiers   []string
 	Documentation []*Text
+	// BuildTags are the build tags that the Go code of the package was read
+	// with, to rename its identifiers in the same files.
+	BuildTags []string `json:",omitempty"`
+}
+
+// Header is the first record of a stream of packages, like the output of
+// typokiller read, telling the version of its format and the tool that wrote
+// it.
+type Header struct {
+	// Schema is the identifier of the JSON Schema of the stream.
+	Schema  string
+	Version int
+	Tool    string `json:",omitempty"`
 }
 
 // Text holds some documentation text.
 type Text struct {
-	Content      string
-	Position     token.Position
-	Misspellings []*Misspelling
-	Package      *Package `json:"-"`
+	Content  string
+	Position token.Position
+	// Identifier is true if Content is a declared name, like the name of a
+	// Go function, made of words in camelCase or snake_case. Position is
+	// that of the declaration.
+	Identifier bool `json:",omitempty"`
+	// Exported is true for identifiers that are part of a public API.
+	Exported bool `json:",omitempty"`
+	// SourceOffsets maps byte offsets of Content to byte offsets in the source
+	// file, relative to Position.Offset. It has one entry more than Content
+	// has bytes. It is only set for texts that are not verbatim copies of the
+	// source, like unquoted string literals, and those must be on a single
+	// line of the source.
+	SourceOffsets []int `json:",omitempty"`
+	Misspellings  []*Misspelling
+	Package       *Package `json:"-"`
+}
+
+// SourceOffset returns the byte offset in the source file, relative to
+// Position.Offset, of the byte at offset in Content.
+func (t *Text) SourceOffset(offset int) int {
+	if t.SourceOffsets == nil || offset < 0 || offset >= len(t.SourceOffsets) {
+		return offset
+	}
+	return t.SourceOffsets[offset]
 }
 
 // Misspelling holds information about a potential misspell.
@@ -24,21 +64,132 @@ type Misspelling struct {
 	Word        string
 	Offset      int
 	Suggestions []string
+	Severity    Severity
 	Action      Action
 	Text        *Text `json:"-"`
 }
 
+// Severity tells how much a potential misspell matters.
+type Severity int
+
+const (
+	// Minor is the severity of misspells seen by readers of the code only,
+	// like in comments and in unexported names.
+	Minor Severity = iota
+	// Major is the severity of misspells in the public API, like in exported
+	// names, which cannot be fixed without breaking users.
+	Major
+)
+
+var severityNames = []string{"minor", "major"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalJSON encodes s as its name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return marshalEnum(int(s), severityNames)
+}
+
+// UnmarshalJSON decodes s from its name, or from a number as written by
+// earlier versions.
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, severityNames)
+	*s = Severity(v)
+	return err
+}
+
+// Position returns the position of the misspelled word in its source file.
+func (m *Misspelling) Position() token.Position {
+	pos := m.Text.Position
+	if m.Text.SourceOffsets != nil {
+		offset := m.Text.SourceOffset(m.Offset)
+		if pos.Offset >= 0 {
+			pos.Offset += offset
+		}
+		pos.Column += offset
+		return pos
+	}
+	if pos.Offset >= 0 {
+		pos.Offset += m.Offset
+	}
+	before := m.Text.Content[:m.Offset]
+	if i := strings.LastIndex(before, "\n"); i >= 0 {
+		pos.Line += strings.Count(before, "\n")
+		pos.Column = m.Offset - i
+	} else {
+		pos.Column += m.Offset
+	}
+	return pos
+}
+
 // Action represents the user action towards a misspell.
 type Action struct {
 	Type        ActionType
 	Replacement string
+	// Confirmed is true if the user confirmed an action that changes the
+	// exported API of a package, like renaming an exported identifier.
+	Confirmed bool `json:",omitempty"`
 }
 
-// ActionType is one of Undefined, Ignore or Replace.
+// ActionType is one of Undefined, Ignore, Replace or Rename.
 type ActionType int
 
 const (
 	Undefined ActionType = iota
 	Ignore
 	Replace
+	// Rename replaces the misspelled word in an identifier, renaming it
+	// and all references to it.
+	Rename
 )
+
+var actionTypeNames = []string{"undefined", "ignore", "replace", "rename"}
+
+func (t ActionType) String() string {
+	if t < 0 || int(t) >= len(actionTypeNames) {
+		return fmt.Sprintf("ActionType(%d)", int(t))
+	}
+	return actionTypeNames[t]
+}
+
+// MarshalJSON encodes t as its name.
+func (t ActionType) MarshalJSON() ([]byte, error) {
+	return marshalEnum(int(t), actionTypeNames)
+}
+
+// UnmarshalJSON decodes t from its name, or from a number as written by
+// earlier versions.
+func (t *ActionType) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, actionTypeNames)
+	*t = ActionType(v)
+	return err
+}
+
+// marshalEnum encodes the value v of an enumeration as its name.
+func marshalEnum(v int, names []string) ([]byte, error) {
+	if v < 0 || v >= len(names) {
+		return nil, fmt.Errorf("invalid value %d, want one of %q", v, names)
+	}
+	return json.Marshal(names[v])
+}
+
+// unmarshalEnum decodes the value of an enumeration from its name or its
+// number.
+func unmarshalEnum(b []byte, names []string) (int, error) {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		for v, s := range names {
+			if s == name {
+				return v, nil
+			}
+		}
+	} else if v, err := strconv.Atoi(string(b)); err == nil && v >= 0 && v < len(names) {
+		return v, nil
+	}
+	return 0, fmt.Errorf("invalid value %s, want one of %q", b, names)
+}
@@ -30,14 +30,20 @@ def spellcheck(pkg):
 if __name__ == "__main__":
     for line in sys.stdin:
         pkg = json.loads(line, object_pairs_hook=OrderedDict)
-        misspelled_documentation = spellcheck(pkg)
-        if misspelled_documentation:
-            try:
-                print json.dumps(OrderedDict([
-                    ("PackageName", pkg.get("PackageName", "")),
-                    ("Documentation", misspelled_documentation),
-                ]), separators=(",", ":"))
-            except IOError as error:
-                if error.errno == errno.EPIPE:
-                    break
-                raise
+        if "Schema" in pkg:
+            # pass the header through, packages keep the same format
+            record = pkg
+        else:
+            misspelled_documentation = spellcheck(pkg)
+            if not misspelled_documentation:
+                continue
+            record = OrderedDict([
+                ("PackageName", pkg.get("PackageName", "")),
+                ("Documentation", misspelled_documentation),
+            ])
+        try:
+            print json.dumps(record, separators=(",", ":"))
+        except IOError as error:
+            if error.errno == errno.EPIPE:
+                break
+            raise